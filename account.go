@@ -1,11 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"sort"
 	"strings"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/organizations"
 	"github.com/aws/aws-sdk-go/service/sts"
 )
 
@@ -46,20 +54,18 @@ func regionFullName(regionCode string) string {
 	return regionName
 }
 
-func printAccountInfo(iamSvc *iam.IAM, stsSvc *sts.STS, region string) AccountInformation {
+// getAccountInfo looks up the account ID and alias of the credentials in use
+func getAccountInfo(ctx context.Context, iamSvc *iam.IAM, stsSvc *sts.STS) (AccountInformation, error) {
 	// Get caller identity
-	callerIdentityOutput, err := stsSvc.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	callerIdentityOutput, err := stsSvc.GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
 	if err != nil {
-		log.Fatalf("Failed to get caller identity: %v", err)
+		return AccountInformation{}, fmt.Errorf("failed to get caller identity: %v", err)
 	}
 
-	// Get Account ID
-	accountID := *callerIdentityOutput.Account
-
 	// List account aliases
-	accountAliasesOutput, err := iamSvc.ListAccountAliases(&iam.ListAccountAliasesInput{})
+	accountAliasesOutput, err := iamSvc.ListAccountAliasesWithContext(ctx, &iam.ListAccountAliasesInput{})
 	if err != nil {
-		log.Fatalf("Failed to list account aliases: %v", err)
+		return AccountInformation{}, fmt.Errorf("failed to list account aliases: %v", err)
 	}
 
 	// Get Account Alias
@@ -68,27 +74,109 @@ func printAccountInfo(iamSvc *iam.IAM, stsSvc *sts.STS, region string) AccountIn
 		accountAlias = *accountAliasesOutput.AccountAliases[0]
 	}
 
-	// Get region full name
-	regionFullName := regionFullName(region)
+	return AccountInformation{
+		AccountId:    *callerIdentityOutput.Account,
+		AccountAlias: accountAlias,
+	}, nil
+}
 
-	// Print banner
+// printAccountInfo prints the account banner for a scan of one or more regions
+func printAccountInfo(w io.Writer, accountInfo AccountInformation, regions []string) {
 	banner := strings.Builder{}
 	banner.WriteString("╔════════════════════════════════════════════════════════════════════════════════════╗\n")
 	banner.WriteString("║\t\t\t\tAccount Information\t\t\t\t\n")
 	banner.WriteString("╠════════════════════════════════════════════════════════════════════════════════════╣\n")
-	banner.WriteString(fmt.Sprintf("║\tAccount ID:\t%-40s\t\n", accountID))
-	banner.WriteString(fmt.Sprintf("║\tAccount Alias:\t%-40s\t\n", accountAlias))
-	banner.WriteString(fmt.Sprintf("║\tRegion Code:\t%-40s\t\n", region))
-	banner.WriteString(fmt.Sprintf("║\tRegion Name:\t%-40s\t\n", regionFullName))
+	banner.WriteString(fmt.Sprintf("║\tAccount ID:\t%-40s\t\n", accountInfo.AccountId))
+	banner.WriteString(fmt.Sprintf("║\tAccount Alias:\t%-40s\t\n", accountInfo.AccountAlias))
+	if len(regions) == 1 {
+		banner.WriteString(fmt.Sprintf("║\tRegion Code:\t%-40s\t\n", regions[0]))
+		banner.WriteString(fmt.Sprintf("║\tRegion Name:\t%-40s\t\n", regionFullName(regions[0])))
+	} else {
+		banner.WriteString(fmt.Sprintf("║\tRegions:\t%-40s\t\n", fmt.Sprintf("%d regions", len(regions))))
+		for _, region := range regions {
+			banner.WriteString(fmt.Sprintf("║\t\t\t%-40s\t\n", region+" - "+regionFullName(region)))
+		}
+	}
 	banner.WriteString("╚════════════════════════════════════════════════════════════════════════════════════╝\n")
 
-	fmt.Print(banner.String())
-	// fill accountinfo struct
-	accountInfo := AccountInformation{
-		AccountId:    accountID,
-		AccountAlias: accountAlias,
-		RegionCode:   region,
-		RegionName:   regionFullName,
+	fmt.Fprint(w, banner.String())
+}
+
+// discoverRegions returns the regions enabled for the account, skipping opt-in regions that
+// have not been opted into
+func discoverRegions(ctx context.Context, svc *ec2.EC2) ([]string, error) {
+	output, err := svc.DescribeRegionsWithContext(ctx, &ec2.DescribeRegionsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("opt-in-status"),
+				Values: aws.StringSlice([]string{"opt-in-not-required", "opted-in"}),
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe regions: %v", err)
+	}
+	regions := make([]string, 0, len(output.Regions))
+	for _, region := range output.Regions {
+		regions = append(regions, aws.StringValue(region.RegionName))
+	}
+	sort.Strings(regions)
+	return regions, nil
+}
+
+// organizationAccount is an account to scan, as listed by Organizations or given with --accounts
+type organizationAccount struct {
+	ID   string
+	Name string
+}
+
+// listOrganizationAccounts returns every active account of the organisation. It has to be called
+// with credentials of the management account or a delegated administrator.
+func listOrganizationAccounts(ctx context.Context, svc *organizations.Organizations) ([]organizationAccount, error) {
+	var accounts []organizationAccount
+	err := svc.ListAccountsPagesWithContext(ctx, &organizations.ListAccountsInput{}, func(page *organizations.ListAccountsOutput, lastPage bool) bool {
+		for _, account := range page.Accounts {
+			if aws.StringValue(account.Status) != organizations.AccountStatusActive {
+				continue
+			}
+			accounts = append(accounts, organizationAccount{
+				ID:   aws.StringValue(account.Id),
+				Name: aws.StringValue(account.Name),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list organization accounts: %v", err)
 	}
-	return accountInfo
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+	return accounts, nil
+}
+
+// partition returns the AWS partition region belongs to
+func partition(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	default:
+		return "aws"
+	}
+}
+
+// roleARN builds the ARN of role in accountID, in the partition of region
+func roleARN(accountID, role, region string) string {
+	return fmt.Sprintf("arn:%s:iam::%s:role/%s", partition(region), accountID, strings.TrimPrefix(role, "/"))
+}
+
+// assumeRoleCredentials returns credentials for role in accountID, assumed with the credentials
+// of sess. They are refreshed automatically when a long scan outlives the session.
+func assumeRoleCredentials(sess *session.Session, accountID string, cfg *scanConfig) *credentials.Credentials {
+	return stscreds.NewCredentials(sess, roleARN(accountID, cfg.Role, cfg.Region), func(p *stscreds.AssumeRoleProvider) {
+		p.RoleSessionName = cfg.SessionName
+		if cfg.ExternalID != "" {
+			p.ExternalID = aws.String(cfg.ExternalID)
+		}
+	})
 }
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// defaultAMIUnusedDays is how many days an AMI has to be unused to be reported
+const defaultAMIUnusedDays = 90
+
+// snapshotImagePattern finds the AMI a snapshot was created for in the description CreateImage
+// and CopyImage give it, such as "Created by CreateImage(i-0123) for ami-0456"
+var snapshotImagePattern = regexp.MustCompile(`(?:for|DestinationAmi) (ami-[0-9a-f]+)`)
+
+// createdForImage reports whether snapshot was created for an AMI. Once the AMI is deregistered
+// the snapshot is reported by ebs-leftover-ami-snapshots only, so that its cost is not counted
+// again by the other snapshot checks.
+func createdForImage(snapshot *ec2.Snapshot) bool {
+	return snapshotImagePattern.MatchString(aws.StringValue(snapshot.Description))
+}
+
+var (
+	publicAMIsCheck = &basicCheck{
+		id:          "ec2-public-amis",
+		service:     "ec2",
+		severity:    SeverityHigh,
+		category:    CategorySecurity,
+		description: "Public AMIs",
+		help:        "Anyone can launch instances from a public AMI and read the data on its snapshots. Make the AMI private unless it is meant to be published, and turn on 'block public access for AMIs' for the region.",
+		arn:         "arn:{partition}:ec2:{region}::image/{id}",
+	}
+	sharedAMIsCheck = &basicCheck{
+		id:          "ec2-amis-shared-externally",
+		service:     "ec2",
+		severity:    SeverityHigh,
+		category:    CategorySecurity,
+		description: "AMIs shared with untrusted accounts",
+		help:        "The accounts can launch instances from the AMI and read the data on its snapshots. Remove them from the launch permissions of the AMI, or add them to --trusted-accounts if the sharing is meant.",
+		arn:         "arn:{partition}:ec2:{region}::image/{id}",
+	}
+	unusedAMIsCheck = &basicCheck{
+		id:          "ec2-unused-amis",
+		service:     "ec2",
+		severity:    SeverityLow,
+		category:    CategoryCost,
+		description: "AMIs not used by any instance or launch template",
+		help:        "The snapshots of an AMI are billed until it is deregistered and its snapshots deleted. Deregister AMIs that are no longer needed, or disable them to keep them out of use.",
+		arn:         "arn:{partition}:ec2:{region}::image/{id}",
+	}
+	leftoverAMISnapshotsCheck = &basicCheck{
+		id:          "ebs-leftover-ami-snapshots",
+		service:     "ebs",
+		severity:    SeverityLow,
+		category:    CategoryCost,
+		description: "Snapshots of deregistered AMIs",
+		help:        "Deregistering an AMI does not delete its snapshots. Delete the snapshots of AMIs that are gone unless they are kept on purpose.",
+		arn:         "arn:{partition}:ec2:{region}::snapshot/{id}",
+	}
+)
+
+func init() {
+	publicAMIsCheck.run = runPublicAMIsCheck
+	sharedAMIsCheck.run = runSharedAMIsCheck
+	unusedAMIsCheck.run = runUnusedAMIsCheck
+	leftoverAMISnapshotsCheck.run = runLeftoverAMISnapshotsCheck
+
+	registerCheck(publicAMIsCheck)
+	registerCheck(sharedAMIsCheck)
+	registerCheck(unusedAMIsCheck)
+	registerCheck(leftoverAMISnapshotsCheck)
+}
+
+// imageStorage returns the size of the snapshots of image and their estimated monthly cost.
+// Snapshots that are not listed, such as the ones of another account, are left out.
+func imageStorage(image *ec2.Image, snapshots map[string]*ec2.Snapshot, prices ebsPrices) (int64, float64) {
+	var size int64
+	var cost float64
+	for _, mapping := range image.BlockDeviceMappings {
+		if mapping.Ebs == nil {
+			continue
+		}
+		if snapshot, ok := snapshots[aws.StringValue(mapping.Ebs.SnapshotId)]; ok {
+			size += aws.Int64Value(snapshot.VolumeSize)
+			cost += prices.snapshotCost(snapshot)
+		}
+	}
+	return size, cost
+}
+
+// snapshotsByID indexes the snapshots of the region of report, for the checks that look up the
+// snapshots of AMIs
+func snapshotsByID(ctx context.Context, clients *Clients, report *Report) (map[string]*ec2.Snapshot, error) {
+	snapshots, err := regionSnapshots(ctx, clients, report)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*ec2.Snapshot, len(snapshots))
+	for _, snapshot := range snapshots {
+		byID[aws.StringValue(snapshot.SnapshotId)] = snapshot
+	}
+	return byID, nil
+}
+
+func imageIds(images []*ec2.Image) []string {
+	ids := make([]string, 0, len(images))
+	for _, image := range images {
+		ids = append(ids, aws.StringValue(image.ImageId))
+	}
+	return ids
+}
+
+func runPublicAMIsCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	images, err := regionImages(ctx, clients, report)
+	if err != nil {
+		return nil, err
+	}
+	report.RecordEvaluated(publicAMIsCheck.id, imageIds(images))
+	var findings []Finding
+	var public []string
+	for _, image := range images {
+		if !aws.BoolValue(image.Public) {
+			continue
+		}
+		id := aws.StringValue(image.ImageId)
+		public = append(public, id)
+		findings = append(findings, publicAMIsCheck.finding(id, "AMI %s (%s) is public", id, aws.StringValue(image.Name)).withTags(ec2Tags(image.Tags)))
+	}
+	report.Update(func(f *Findings) {
+		f.Images.TotalAnalyzed = len(images)
+		f.Images.PublicImageIds = public
+	})
+	return findings, nil
+}
+
+func runSharedAMIsCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	images, err := regionImages(ctx, clients, report)
+	if err != nil {
+		return nil, err
+	}
+	report.RecordEvaluated(sharedAMIsCheck.id, imageIds(images))
+	var findings []Finding
+	shared := make(map[string][]string)
+	for _, image := range images {
+		id := aws.StringValue(image.ImageId)
+		out, err := clients.EC2.DescribeImageAttributeWithContext(ctx, &ec2.DescribeImageAttributeInput{
+			Attribute: aws.String(ec2.ImageAttributeNameLaunchPermission),
+			ImageId:   aws.String(id),
+		})
+		if err != nil {
+			return findings, fmt.Errorf("failed to describe the launch permissions of %s: %v", id, err)
+		}
+		// Sharing with an organisation or organisational unit is left out, it is usually the own one
+		var untrusted []string
+		for _, permission := range out.LaunchPermissions {
+			if account := aws.StringValue(permission.UserId); account != "" && !contains(clients.TrustedAccounts, account) {
+				untrusted = append(untrusted, account)
+			}
+		}
+		if len(untrusted) == 0 {
+			continue
+		}
+		sort.Strings(untrusted)
+		shared[id] = untrusted
+		findings = append(findings, sharedAMIsCheck.finding(id,
+			"AMI %s (%s) is shared with accounts that are not trusted: %s", id, aws.StringValue(image.Name), strings.Join(untrusted, ", ")).
+			withTags(ec2Tags(image.Tags)).withAttribute("untrustedAccounts", strings.Join(untrusted, ",")))
+	}
+	report.Update(func(f *Findings) {
+		f.Images.TotalAnalyzed = len(images)
+		f.Images.SharedWithUntrustedAccounts = shared
+	})
+	return findings, nil
+}
+
+// imagesInUse returns the AMIs of the instances that are not terminated and of the default and
+// latest version of every launch template
+func imagesInUse(ctx context.Context, svc *ec2.EC2) (map[string]bool, error) {
+	inUse := make(map[string]bool)
+	instances, err := describeInstances(ctx, svc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe instances: %v", err)
+	}
+	for _, instance := range instances {
+		if aws.StringValue(instance.State.Name) != ec2.InstanceStateNameTerminated {
+			inUse[aws.StringValue(instance.ImageId)] = true
+		}
+	}
+	// Without a launch template ID or name, the versions of every launch template are listed
+	err = svc.DescribeLaunchTemplateVersionsPagesWithContext(ctx, &ec2.DescribeLaunchTemplateVersionsInput{
+		Versions: aws.StringSlice([]string{"$Default", "$Latest"}),
+	}, func(page *ec2.DescribeLaunchTemplateVersionsOutput, lastPage bool) bool {
+		for _, version := range page.LaunchTemplateVersions {
+			if data := version.LaunchTemplateData; data != nil && data.ImageId != nil {
+				inUse[*data.ImageId] = true
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe launch templates: %v", err)
+	}
+	return inUse, nil
+}
+
+// imageLastUsed is when an instance was last launched from image, or when it was created if it
+// never was
+func imageLastUsed(ctx context.Context, svc *ec2.EC2, image *ec2.Image) (time.Time, bool, error) {
+	out, err := svc.DescribeImageAttributeWithContext(ctx, &ec2.DescribeImageAttributeInput{
+		Attribute: aws.String(ec2.ImageAttributeNameLastLaunchedTime),
+		ImageId:   image.ImageId,
+	})
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to describe when %s was last launched: %v", aws.StringValue(image.ImageId), err)
+	}
+	if out.LastLaunchedTime != nil && aws.StringValue(out.LastLaunchedTime.Value) != "" {
+		launched, err := time.Parse(time.RFC3339, aws.StringValue(out.LastLaunchedTime.Value))
+		if err == nil {
+			return launched, true, nil
+		}
+	}
+	created, err := time.Parse(time.RFC3339, aws.StringValue(image.CreationDate))
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid creation date of %s: %v", aws.StringValue(image.ImageId), err)
+	}
+	return created, false, nil
+}
+
+func runUnusedAMIsCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	images, err := regionImages(ctx, clients, report)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := snapshotsByID(ctx, clients, report)
+	if err != nil {
+		return nil, err
+	}
+	inUse, err := imagesInUse(ctx, clients.EC2)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-clients.AMIUnusedAge)
+	prices := regionEBSPrices(clients, report, unusedAMIsCheck.id)
+
+	report.RecordEvaluated(unusedAMIsCheck.id, imageIds(images))
+	var findings []Finding
+	var costs []ImageCost
+	var unused []string
+	for _, image := range images {
+		id := aws.StringValue(image.ImageId)
+		size, cost := imageStorage(image, snapshots, prices)
+		costs = append(costs, ImageCost{ImageId: id, Name: aws.StringValue(image.Name), SnapshotsGB: size, MonthlyCost: cost})
+		if inUse[id] {
+			continue
+		}
+		lastUsed, launched, err := imageLastUsed(ctx, clients.EC2, image)
+		if err != nil {
+			return findings, err
+		}
+		if lastUsed.After(cutoff) {
+			continue
+		}
+		days := daysSince(lastUsed)
+		since := fmt.Sprintf("never launched, created %d days ago", days)
+		if launched {
+			since = fmt.Sprintf("last launched %d days ago", days)
+		}
+		unused = append(unused, id)
+		findings = append(findings, unusedAMIsCheck.finding(id,
+			"AMI %s (%s) is not used by any instance or launch template, %s; its %d GB of snapshots cost up to $%.2f per month",
+			id, aws.StringValue(image.Name), since, size, cost).withTags(ec2Tags(image.Tags)).withMonthlyCost(cost))
+	}
+	sort.Slice(costs, func(i, j int) bool { return costs[i].MonthlyCost > costs[j].MonthlyCost })
+	report.Update(func(f *Findings) {
+		f.Images.TotalAnalyzed = len(images)
+		f.Images.UnusedImageIds = unused
+		f.Images.Costs = costs
+	})
+	return findings, nil
+}
+
+func runLeftoverAMISnapshotsCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	snapshots, err := regionSnapshots(ctx, clients, report)
+	if err != nil {
+		return nil, err
+	}
+	images, err := regionImages(ctx, clients, report)
+	if err != nil {
+		return nil, err
+	}
+	registered := make(map[string]bool, len(images))
+	for _, id := range imageIds(images) {
+		registered[id] = true
+	}
+	usedByImages := imageSnapshotIds(images)
+	prices := regionEBSPrices(clients, report, leftoverAMISnapshotsCheck.id)
+
+	report.RecordEvaluated(leftoverAMISnapshotsCheck.id, getSnapshotIds(snapshots))
+	var findings []Finding
+	var leftover []string
+	for _, snapshot := range completedSnapshots(snapshots) {
+		id := aws.StringValue(snapshot.SnapshotId)
+		match := snapshotImagePattern.FindStringSubmatch(aws.StringValue(snapshot.Description))
+		if match == nil || registered[match[1]] || usedByImages[id] {
+			continue
+		}
+		cost := prices.snapshotCost(snapshot)
+		leftover = append(leftover, id)
+		findings = append(findings, leftoverAMISnapshotsCheck.finding(id,
+			"Snapshot %s was created for AMI %s, which is deregistered; its %d GB cost up to $%.2f per month",
+			id, match[1], aws.Int64Value(snapshot.VolumeSize), cost).withTags(ec2Tags(snapshot.Tags)).withMonthlyCost(cost))
+	}
+	report.Update(func(f *Findings) {
+		f.Images.LeftoverSnapshotIds = leftover
+	})
+	return findings, nil
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The JSON API of 'avm serve --listen': scans can be started and their findings read over HTTP.
+//
+//	POST /scans                 start a scan, see scanRequest
+//	GET  /scans                 list the scans of this process, most recent first
+//	GET  /scans/{id}            status and progress of a scan
+//	GET  /scans/{id}/findings   findings of a finished scan, filtered by ?service=, ?severity=,
+//	                            ?check=, ?account= and ?region=, each a comma separated list
+//	GET  /checks                every check avm can run
+
+const (
+	scanQueued    = "queued"
+	scanRunning   = "running"
+	scanSucceeded = "succeeded"
+	scanFailed    = "failed"
+
+	// triggerScan is the origin of the runs of 'avm scan' in the history
+	triggerScan     = "scan"
+	triggerSchedule = "schedule"
+	triggerAPI      = "api"
+
+	// maxAPIScans is how many scans started through the API run at the same time; others queue
+	maxAPIScans = 2
+	// maxKeptScans is how many finished scans are kept in memory for the API
+	maxKeptScans = 50
+	// maxRequestBytes bounds the body of POST /scans
+	maxRequestBytes = 1 << 20
+)
+
+// scanRequest is the body of POST /scans. Fields left out keep the value avm serve was started
+// with; checks and disable select checks the way --checks and --disable do. The role and external
+// ID cannot be changed, and accounts must be among the ones avm serve was started with, so that a
+// caller cannot have avm assume roles in accounts it was not set up to scan.
+type scanRequest struct {
+	Regions      []string `json:"regions,omitempty"`
+	Accounts     []string `json:"accounts,omitempty"`
+	Checks       []string `json:"checks,omitempty"`
+	Disable      []string `json:"disable,omitempty"`
+	CPUThreshold int      `json:"cpuThreshold,omitempty"`
+	Timeframe    string   `json:"timeframe,omitempty"`
+}
+
+// config returns the scan configuration of the request, starting from base
+func (r scanRequest) config(base *scanConfig) (*scanConfig, error) {
+	cfg := *base
+	cfg.progress = nil
+	if len(r.Regions) > 0 {
+		cfg.Regions = splitList(strings.Join(r.Regions, ","))
+	}
+	if len(r.Accounts) > 0 {
+		cfg.Accounts = splitList(strings.Join(r.Accounts, ","))
+		if err := checkAccountScope(base.Accounts, cfg.Accounts); err != nil {
+			return nil, err
+		}
+		// Accounts of the organisation are only known once it is listed, see resolveAccounts
+		cfg.organizationScope = contains(base.Accounts, organizationAccounts) && !contains(cfg.Accounts, organizationAccounts)
+	}
+	if len(r.Checks) > 0 || len(r.Disable) > 0 {
+		var err error
+		cfg.Checks, err = selectChecks(splitList(strings.Join(r.Checks, ",")), splitList(strings.Join(r.Disable, ",")))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if r.CPUThreshold != 0 {
+		cfg.CPUThreshold = r.CPUThreshold
+	}
+	if r.Timeframe != "" {
+		var err error
+		if cfg.Timeframe, err = parseTimeframe(r.Timeframe); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// checkAccountScope returns an error unless every account of requested is one of allowed, the
+// --accounts of avm serve. With organizationAccounts any account may be requested; it is checked
+// against the organisation when the scan starts.
+func checkAccountScope(allowed, requested []string) error {
+	if len(allowed) == 0 {
+		return errors.New("accounts cannot be chosen, avm serve only scans the account of its credentials; start it with --accounts to allow others")
+	}
+	if contains(allowed, organizationAccounts) {
+		return nil
+	}
+	for _, account := range requested {
+		if !contains(allowed, account) {
+			return fmt.Errorf("account %s is not one of the accounts avm serve was started with", account)
+		}
+	}
+	return nil
+}
+
+// scanJob is a scan run by avm serve, on its schedule or through the API
+type scanJob struct {
+	id      string
+	trigger string
+	cfg     *scanConfig
+	created time.Time
+
+	mu       sync.Mutex
+	status   string
+	started  time.Time
+	finished time.Time
+	err      string
+	run      uint64
+	report   ScanReport
+}
+
+type scanJobProgress struct {
+	ChecksDone    int `json:"checksDone"`
+	ChecksPlanned int `json:"checksPlanned"`
+}
+
+// scanJobView is how a scan is shown by the API
+type scanJobView struct {
+	ID         string               `json:"id"`
+	Trigger    string               `json:"trigger"`
+	Status     string               `json:"status"`
+	CreatedAt  time.Time            `json:"createdAt"`
+	StartedAt  *time.Time           `json:"startedAt,omitempty"`
+	FinishedAt *time.Time           `json:"finishedAt,omitempty"`
+	Error      string               `json:"error,omitempty"`
+	Progress   scanJobProgress      `json:"progress"`
+	HistoryRun uint64               `json:"historyRun,omitempty"`
+	Summary    *OrganizationSummary `json:"summary,omitempty"`
+}
+
+func (j *scanJob) view() scanJobView {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	v := scanJobView{
+		ID:         j.id,
+		Trigger:    j.trigger,
+		Status:     j.status,
+		CreatedAt:  j.created,
+		Error:      j.err,
+		HistoryRun: j.run,
+	}
+	v.Progress.ChecksDone, v.Progress.ChecksPlanned = j.cfg.progress.counts()
+	if !j.started.IsZero() {
+		started := j.started
+		v.StartedAt = &started
+	}
+	if !j.finished.IsZero() {
+		finished := j.finished
+		v.FinishedAt = &finished
+	}
+	if j.status == scanSucceeded {
+		summary := j.report.Summary
+		v.Summary = &summary
+	}
+	return v
+}
+
+func (j *scanJob) start() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status, j.started = scanRunning, time.Now()
+}
+
+func (j *scanJob) fail(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status, j.finished, j.err = scanFailed, time.Now(), err.Error()
+}
+
+func (j *scanJob) succeed(report ScanReport, run historyRun) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status, j.finished, j.report, j.run = scanSucceeded, time.Now(), report, run.ID
+}
+
+// findings returns the findings of a finished scan, or false while it is not done
+func (j *scanJob) findings() ([]Finding, string, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.status != scanSucceeded {
+		return nil, j.status, false
+	}
+	return j.report.findings(), j.status, true
+}
+
+// scanJobs keeps the scans of this process, the running ones and the last maxKeptScans finished
+type scanJobs struct {
+	mu   sync.Mutex
+	jobs []*scanJob
+	next int
+}
+
+func (s *scanJobs) add(trigger string, cfg *scanConfig) *scanJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	cfg.progress = &scanProgress{}
+	job := &scanJob{id: strconv.Itoa(s.next), trigger: trigger, cfg: cfg, created: time.Now(), status: scanQueued}
+
+	// Forget the oldest finished scans
+	finished := 0
+	for _, j := range s.jobs {
+		if v := j.view(); v.Status == scanSucceeded || v.Status == scanFailed {
+			finished++
+		}
+	}
+	kept := s.jobs[:0]
+	for _, j := range s.jobs {
+		if v := j.view(); finished >= maxKeptScans && (v.Status == scanSucceeded || v.Status == scanFailed) {
+			finished--
+			continue
+		}
+		kept = append(kept, j)
+	}
+	s.jobs = append(kept, job)
+	return job
+}
+
+func (s *scanJobs) get(id string) *scanJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.id == id {
+			return j
+		}
+	}
+	return nil
+}
+
+// list returns every scan kept, most recent first
+func (s *scanJobs) list() []scanJobView {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	views := make([]scanJobView, 0, len(s.jobs))
+	for i := len(s.jobs) - 1; i >= 0; i-- {
+		views = append(views, s.jobs[i].view())
+	}
+	return views
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	encoder.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, apiError{Error: fmt.Sprintf(format, args...)})
+}
+
+// requireToken rejects requests without the bearer token, when there is one
+func requireToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing or wrong bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiCheck is a check as listed by GET /checks
+type apiCheck struct {
+	ID          string   `json:"id"`
+	Service     string   `json:"service"`
+	Severity    Severity `json:"severity"`
+	Category    Category `json:"category"`
+	Description string   `json:"description"`
+	Help        string   `json:"help"`
+	OptIn       bool     `json:"optIn"`
+	Global      bool     `json:"global"`
+}
+
+func (d *daemon) handleChecks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+	checks := []apiCheck{}
+	for _, c := range registeredChecks() {
+		checks = append(checks, apiCheck{
+			ID:          c.ID(),
+			Service:     c.Service(),
+			Severity:    c.Severity(),
+			Category:    c.Category(),
+			Description: c.Description(),
+			Help:        checkHelp(c),
+			OptIn:       isOptIn(c),
+			Global:      isGlobal(c),
+		})
+	}
+	writeJSON(w, http.StatusOK, checks)
+}
+
+// handleScans serves /scans and everything under it
+func (d *daemon) handleScans(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/scans"), "/")
+	if path == "" {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, d.scans.list())
+		case http.MethodPost:
+			d.startScan(w, r)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		}
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+	parts := strings.Split(path, "/")
+	job := d.scans.get(parts[0])
+	if job == nil || len(parts) > 2 || (len(parts) == 2 && parts[1] != "findings") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if len(parts) == 1 {
+		writeJSON(w, http.StatusOK, job.view())
+		return
+	}
+	d.scanFindings(w, r, job)
+}
+
+func (d *daemon) startScan(w http.ResponseWriter, r *http.Request) {
+	var request scanRequest
+	decoder := json.NewDecoder(io.LimitReader(r.Body, maxRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid scan request: %v", err)
+		return
+	}
+	cfg, err := request.config(d.cfg)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid scan request: %v", err)
+		return
+	}
+	job := d.scans.add(triggerAPI, cfg)
+	go func() {
+		select {
+		case d.apiSlots <- struct{}{}:
+		case <-d.ctx.Done():
+			job.fail(errors.New("avm serve is shutting down"))
+			return
+		}
+		defer func() { <-d.apiSlots }()
+		d.execute(d.ctx, job)
+	}()
+	w.Header().Set("Location", "/scans/"+job.id)
+	writeJSON(w, http.StatusAccepted, job.view())
+}
+
+// scanFindings serves the findings of a finished scan
+func (d *daemon) scanFindings(w http.ResponseWriter, r *http.Request, job *scanJob) {
+	findings, status, ok := job.findings()
+	if !ok {
+		writeError(w, http.StatusConflict, "scan %s is %s, findings are only available once it succeeded", job.id, status)
+		return
+	}
+	query := r.URL.Query()
+	filters := map[string]func(Finding) string{
+		"service":  func(f Finding) string { return f.Service },
+		"severity": func(f Finding) string { return string(f.Severity) },
+		"check":    func(f Finding) string { return f.CheckID },
+		"account":  func(f Finding) string { return f.AccountID },
+		"region":   func(f Finding) string { return f.Region },
+	}
+	for name := range query {
+		if filters[name] == nil {
+			writeError(w, http.StatusBadRequest, "unknown filter %q, expected service, severity, check, account or region", name)
+			return
+		}
+	}
+	if values := splitList(query.Get("severity")); len(values) > 0 {
+		for _, value := range values {
+			if _, err := parseSeverity(value); err != nil {
+				writeError(w, http.StatusBadRequest, "invalid severity filter: %v", err)
+				return
+			}
+		}
+	}
+
+	selected := []Finding{}
+	for _, f := range findings {
+		keep := true
+		for name, field := range filters {
+			if values := splitList(query.Get(name)); len(values) > 0 && !contains(values, strings.ToLower(field(f))) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			selected = append(selected, f)
+		}
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Scan     string    `json:"scan"`
+		Count    int       `json:"count"`
+		Findings []Finding `json:"findings"`
+	}{job.id, len(selected), selected})
+}
+
+// execute runs a scan and saves it to the history
+func (d *daemon) execute(ctx context.Context, job *scanJob) (ScanReport, historyRun, error) {
+	job.start()
+	start := time.Now()
+	d.logger.Printf("Scan %s started (%s)", job.id, job.trigger)
+	report, err := scan(ctx, job.cfg, io.Discard)
+	if err != nil {
+		if ctx.Err() != nil {
+			d.logger.Printf("Scan %s interrupted", job.id)
+		} else {
+			d.logger.Printf("❌ Scan %s failed: %v", job.id, err)
+		}
+		job.fail(err)
+		return ScanReport{}, historyRun{}, err
+	}
+	d.logger.Printf("Scan %s finished in %s: %d findings", job.id, time.Since(start).Round(time.Second), report.Summary.TotalFindings)
+
+	var run historyRun
+	if job.cfg.History != "" {
+		if run, err = saveRun(job.cfg, job.trigger, report); err != nil {
+			d.logger.Printf("⚠️  Scan %s not saved to the history: %v", job.id, err)
+		} else {
+			d.logger.Printf("Scan %s saved as run %d", job.id, run.ID)
+		}
+	}
+	job.succeed(report, run)
+	return report, run, nil
+}
@@ -1,12 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/support"
 )
 
@@ -15,23 +14,32 @@ type CheckInfo struct {
 	CheckName string
 }
 
-func getTrustedAdvisorCheckIds() ([]CheckInfo, error) {
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String("us-east-1"),
-	})
-	if err != nil {
-		return nil, err
-	}
+// TrustedAdvisorResult is a Trusted Advisor check that did not come back ok
+type TrustedAdvisorResult struct {
+	CheckName        string `json:"checkName"`
+	Status           string `json:"status"`
+	FlaggedResources int    `json:"flaggedResources"`
+	// Resources are the flagged resources, with the metadata columns of the check
+	Resources []TrustedAdvisorResource `json:"resources,omitempty"`
+}
 
-	svc := support.New(sess)
+// TrustedAdvisorResource is a resource flagged by a Trusted Advisor check
+type TrustedAdvisorResource struct {
+	ResourceId string   `json:"resourceId"`
+	Region     string   `json:"region,omitempty"`
+	Status     string   `json:"status"`
+	Metadata   []string `json:"metadata"`
+}
+
+func getTrustedAdvisorCheckIds(ctx context.Context, svc *support.Support) ([]CheckInfo, error) {
 	input := &support.DescribeTrustedAdvisorChecksInput{
 		Language: aws.String("en"),
 	}
 
-	result, err := svc.DescribeTrustedAdvisorChecks(input)
+	result, err := svc.DescribeTrustedAdvisorChecksWithContext(ctx, input)
 	if err != nil {
 		if strings.Contains(err.Error(), "SubscriptionRequiredException") {
-			fmt.Println("Please run this check from an account with the correct support plan")
+			return nil, fmt.Errorf("please run this check from an account with the correct support plan")
 		} else {
 			return nil, err
 		}
@@ -48,21 +56,9 @@ func getTrustedAdvisorCheckIds() ([]CheckInfo, error) {
 	return checkInfos, nil
 }
 
-func getCheckResults(checkInfos []CheckInfo) error {
-	file, err := os.Create("trusted-advisor-findings.txt")
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String("us-east-1"),
-	})
-	if err != nil {
-		return err
-	}
-
-	svc := support.New(sess)
+// getCheckResults returns every check that is not ok, with its flagged resources
+func getCheckResults(ctx context.Context, svc *support.Support, checkInfos []CheckInfo) ([]TrustedAdvisorResult, error) {
+	var flagged []TrustedAdvisorResult
 
 	for _, checkInfo := range checkInfos {
 		input := &support.DescribeTrustedAdvisorCheckResultInput{
@@ -70,32 +66,68 @@ func getCheckResults(checkInfos []CheckInfo) error {
 			Language: aws.String("en"),
 		}
 
-		result, err := svc.DescribeTrustedAdvisorCheckResult(input)
+		result, err := svc.DescribeTrustedAdvisorCheckResultWithContext(ctx, input)
 		if err != nil {
-			return err
+			return flagged, err
 		}
 
-		if *result.Result.Status != "ok" && *result.Result.Status != "not_available" {
-			fmt.Fprintf(file, "\n-------------------------\n")
-			fmt.Fprintf(file, "Check Name: %s\n", checkInfo.CheckName)
-			fmt.Fprintf(file, "Status: %s\n", *result.Result.Status)
-			fmt.Fprintf(file, "Resources Summary: %+v\n", result.Result.ResourcesSummary)
-			fmt.Fprintf(file, "Flagged resources are below:\n")
-			for _, resource := range result.Result.FlaggedResources {
-				fmt.Fprintf(file, "\tResource ID: %s\n", *resource.ResourceId)
-				metadataStrings := make([]string, len(resource.Metadata))
-				for i, metadata := range resource.Metadata {
-					if metadata != nil {
-						metadataStrings[i] = *metadata
-					} else {
-						metadataStrings[i] = "" // Or some placeholder for nil metadata
-					}
-				}
-				fmt.Fprintf(file, "\t\tMetadata: %s\n", strings.Join(metadataStrings, ", "))
-			}
-			fmt.Fprintf(file, "\n-------------------------\n")
+		status := aws.StringValue(result.Result.Status)
+		if status == "ok" || status == "not_available" {
+			continue
+		}
+		checkResult := TrustedAdvisorResult{
+			CheckName:        checkInfo.CheckName,
+			Status:           status,
+			FlaggedResources: len(result.Result.FlaggedResources),
 		}
+		for _, resource := range result.Result.FlaggedResources {
+			checkResult.Resources = append(checkResult.Resources, TrustedAdvisorResource{
+				ResourceId: aws.StringValue(resource.ResourceId),
+				Region:     aws.StringValue(resource.Region),
+				Status:     aws.StringValue(resource.Status),
+				// Columns the resource has no value for are left empty
+				Metadata: aws.StringValueSlice(resource.Metadata),
+			})
+		}
+		flagged = append(flagged, checkResult)
 	}
 
-	return nil
+	return flagged, nil
+}
+
+var trustedAdvisorCheck = &basicCheck{
+	id:          "trusted-advisor",
+	service:     "support",
+	severity:    SeverityMedium,
+	category:    CategoryHygiene,
+	description: "Trusted Advisor checks that are not ok",
+	help:        "Review the flagged resources, listed under trustedAdvisor in the JSON report of the account or in the Trusted Advisor console, and follow the recommended action of each check.",
+	optIn:       true,
+	global:      true,
+}
+
+func init() {
+	trustedAdvisorCheck.run = runTrustedAdvisorCheck
+	registerCheck(trustedAdvisorCheck)
+}
+
+func runTrustedAdvisorCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	// get Trusted Advisor checks-- available only on higher support plans. clients.Support is always in us-east-1
+	checkInfos, err := getTrustedAdvisorCheckIds(ctx, clients.Support)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Trusted Advisor check IDs: %v", err)
+	}
+	results, err := getCheckResults(ctx, clients.Support, checkInfos)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Trusted Advisor check results: %v", err)
+	}
+	report.Update(func(f *Findings) {
+		f.TrustedAdvisor = results
+	})
+	var findings []Finding
+	for _, result := range results {
+		findings = append(findings, trustedAdvisorCheck.finding(result.CheckName,
+			"%s: %s, %d flagged resources", result.CheckName, result.Status, result.FlaggedResources))
+	}
+	return findings, nil
 }
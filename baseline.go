@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// baselineVersion is written to every baseline file, so that the format can change later
+const baselineVersion = 1
+
+// fingerprint identifies what a finding is about: the check, the account, region and resource
+// it was found on and its attributes. The message is left out, as it may hold figures that change
+// from one scan to the next, such as the average CPU of an underutilized instance.
+func fingerprint(f Finding) string {
+	parts := []string{f.CheckID, f.AccountID, f.Region, f.ResourceID}
+	names := make([]string, 0, len(f.Attributes))
+	for name := range f.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, name+"="+f.Attributes[name])
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// baselineFinding is a finding as written to a baseline file. Only the fingerprint is compared,
+// the rest is there so that changes to a committed baseline can be reviewed.
+type baselineFinding struct {
+	Fingerprint string            `json:"fingerprint"`
+	CheckID     string            `json:"checkId"`
+	AccountID   string            `json:"accountId"`
+	Region      string            `json:"region"`
+	ResourceID  string            `json:"resourceId,omitempty"`
+	Attributes  map[string]string `json:"attributes,omitempty"`
+	Severity    Severity          `json:"severity"`
+	Message     string            `json:"message"`
+}
+
+// Baseline holds the findings accepted when the baseline was created. With --baseline only
+// findings that are not in it are reported and count towards --fail-on.
+type Baseline struct {
+	Version   int               `json:"version"`
+	CreatedAt time.Time         `json:"createdAt"`
+	Findings  []baselineFinding `json:"findings"`
+
+	fingerprints map[string]bool
+}
+
+func loadBaseline(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read baseline file: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var baseline Baseline
+	if err := decoder.Decode(&baseline); err != nil {
+		return nil, fmt.Errorf("invalid baseline file %s: %v", path, err)
+	}
+	if baseline.Version != baselineVersion {
+		return nil, fmt.Errorf("invalid baseline file %s: unsupported version %d, expected %d", path, baseline.Version, baselineVersion)
+	}
+	baseline.fingerprints = make(map[string]bool, len(baseline.Findings))
+	for i, f := range baseline.Findings {
+		if f.Fingerprint == "" {
+			return nil, fmt.Errorf("invalid baseline file %s: finding %d has no fingerprint", path, i+1)
+		}
+		baseline.fingerprints[f.Fingerprint] = true
+	}
+	return &baseline, nil
+}
+
+// split returns the findings that are not in the baseline and the ones that are. A nil baseline
+// contains nothing.
+func (b *Baseline) split(findings []Finding) (reported, baselined []Finding) {
+	if b == nil {
+		return findings, nil
+	}
+	for _, f := range findings {
+		if b.fingerprints[f.Fingerprint] {
+			baselined = append(baselined, f)
+		} else {
+			reported = append(reported, f)
+		}
+	}
+	return reported, baselined
+}
+
+// renderBaseline writes every finding of the scan as a baseline, including the ones left out by
+// an earlier baseline, so that a baseline can be refreshed with --baseline given. Suppressed
+// findings are not included.
+func renderBaseline(w io.Writer, report ScanReport) error {
+	baseline := Baseline{Version: baselineVersion, CreatedAt: time.Now().UTC(), Findings: []baselineFinding{}}
+	seen := make(map[string]bool)
+	for _, f := range append(report.findings(), report.baselined()...) {
+		if seen[f.Fingerprint] {
+			continue
+		}
+		seen[f.Fingerprint] = true
+		baseline.Findings = append(baseline.Findings, baselineFinding{
+			Fingerprint: f.Fingerprint,
+			CheckID:     f.CheckID,
+			AccountID:   f.AccountID,
+			Region:      f.Region,
+			ResourceID:  f.ResourceID,
+			Attributes:  f.Attributes,
+			Severity:    f.Severity,
+			Message:     f.Message,
+		})
+	}
+	// A stable order keeps the diff of a committed baseline small
+	sort.SliceStable(baseline.Findings, func(i, j int) bool {
+		a, b := baseline.Findings[i], baseline.Findings[j]
+		if a.AccountID != b.AccountID {
+			return a.AccountID < b.AccountID
+		}
+		if a.Region != b.Region {
+			return a.Region < b.Region
+		}
+		if a.CheckID != b.CheckID {
+			return a.CheckID < b.CheckID
+		}
+		if a.ResourceID != b.ResourceID {
+			return a.ResourceID < b.ResourceID
+		}
+		return a.Fingerprint < b.Fingerprint
+	})
+
+	jsonData, err := json.MarshalIndent(baseline, "", "    ")
+	if err != nil {
+		return fmt.Errorf("error with parsing baseline data: %v", err)
+	}
+	_, err = fmt.Fprintln(w, string(jsonData))
+	return err
+}
+
+const baselineUsageText = `Usage:
+  avm baseline create [scan flags] > baseline.json
+
+Runs a scan and writes every finding to a baseline file. Give it to 'avm scan --baseline' to only
+report the findings that are not in it. Takes the same flags as 'avm scan', apart from --output;
+the scan is not saved to the history, notified, uploaded, exported to Security Hub or gated with
+--fail-on.
+
+Flags:
+`
+
+func runBaselineCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "create" {
+		return fmt.Errorf("usage: avm baseline create [scan flags]")
+	}
+	cfg, err := parseScanFlags(args[1:], baselineUsageText)
+	if err != nil {
+		return err
+	}
+	// The baseline is all the scan is for
+	cfg.History, cfg.SecurityHub, cfg.FailOn = "", false, ""
+	cfg.Upload, cfg.Notify = uploadOptions{}, notifyOptions{}
+
+	out, progress, closeOut, err := openOutput(cfg.OutFile, false)
+	if err != nil {
+		return err
+	}
+	defer closeOut()
+	report, err := scan(ctx, cfg, progress)
+	if err != nil {
+		return err
+	}
+	if err := renderBaseline(out, report); err != nil {
+		return err
+	}
+	if err := closeOut(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", cfg.OutFile, err)
+	}
+	// A baseline of an incomplete scan would let the findings it missed through
+	return incompleteScan(report)
+}
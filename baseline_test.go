@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestFingerprint(t *testing.T) {
+	base := Finding{
+		CheckID:    "ebs-volume-optimization",
+		AccountID:  "111111111111",
+		Region:     "us-east-1",
+		ResourceID: "vol-1",
+		Attributes: map[string]string{"issue": "gp2", "type": "gp2"},
+		Message:    "saves $1.00 per month",
+	}
+	tests := []struct {
+		name   string
+		change func(f *Finding)
+		same   bool
+	}{
+		{"message", func(f *Finding) { f.Message = "saves $2.00 per month" }, true},
+		{"severity", func(f *Finding) { f.Severity = SeverityHigh }, true},
+		{"tags", func(f *Finding) { f.Tags = map[string]string{"Team": "data"} }, true},
+		{"attribute order", func(f *Finding) { f.Attributes = map[string]string{"type": "gp2", "issue": "gp2"} }, true},
+		{"check", func(f *Finding) { f.CheckID = "ebs-orphaned-volumes" }, false},
+		{"account", func(f *Finding) { f.AccountID = "222222222222" }, false},
+		{"region", func(f *Finding) { f.Region = "eu-west-1" }, false},
+		{"resource", func(f *Finding) { f.ResourceID = "vol-2" }, false},
+		{"attribute value", func(f *Finding) { f.Attributes = map[string]string{"issue": "iops", "type": "gp2"} }, false},
+		{"attribute added", func(f *Finding) { f.Attributes = map[string]string{"issue": "gp2", "type": "gp2", "x": ""} }, false},
+		{"attributes removed", func(f *Finding) { f.Attributes = nil }, false},
+		// the fields are separated, so that moving text from one to the next changes the fingerprint
+		{"field boundary", func(f *Finding) { f.Region, f.ResourceID = "us-east-1vol", "-1" }, false},
+	}
+	want := fingerprint(base)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := base
+			tt.change(&f)
+			if got := fingerprint(f); (got == want) != tt.same {
+				t.Errorf("fingerprint changed = %v, want %v", got != want, !tt.same)
+			}
+		})
+	}
+}
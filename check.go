@@ -0,0 +1,442 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical"
+	SeverityHigh     Severity = "high"
+	SeverityMedium   Severity = "medium"
+	SeverityLow      Severity = "low"
+	SeverityInfo     Severity = "info"
+)
+
+// severities lists every severity, most severe first
+var severities = []Severity{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo}
+
+// rank orders severities, the most severe having the highest rank. Unknown severities rank lowest.
+func (s Severity) rank() int {
+	for i, severity := range severities {
+		if s == severity {
+			return len(severities) - i
+		}
+	}
+	return 0
+}
+
+// atLeast reports whether s is as severe as threshold or more
+func (s Severity) atLeast(threshold Severity) bool {
+	return s.rank() >= threshold.rank()
+}
+
+func parseSeverity(value string) (Severity, error) {
+	for _, severity := range severities {
+		if strings.EqualFold(value, string(severity)) {
+			return severity, nil
+		}
+	}
+	names := make([]string, len(severities))
+	for i, severity := range severities {
+		names[i] = string(severity)
+	}
+	return "", fmt.Errorf("unknown severity %q, expected one of: %s", value, strings.Join(names, ", "))
+}
+
+// Category is what kind of problem a finding is about
+type Category string
+
+const (
+	CategorySecurity    Category = "security"
+	CategoryCost        Category = "cost"
+	CategoryReliability Category = "reliability"
+	CategoryHygiene     Category = "hygiene"
+)
+
+// Finding is a single result reported by a check, usually about one resource
+type Finding struct {
+	AccountID  string   `json:"accountId"`
+	Region     string   `json:"region"`
+	CheckID    string   `json:"checkId"`
+	Service    string   `json:"service"`
+	Severity   Severity `json:"severity"`
+	Category   Category `json:"category"`
+	ResourceID string   `json:"resourceId"`
+	// ResourceARN is empty when the finding is not about a single AWS resource
+	ResourceARN string `json:"resourceArn,omitempty"`
+	Message     string `json:"message"`
+	// Tags of the resource, when the check has them at hand; suppressions can match on them
+	Tags map[string]string `json:"tags,omitempty"`
+	// MonthlyCost is the estimated cost in USD of leaving the resource as it is, when the check knows it
+	MonthlyCost float64 `json:"monthlyCostUSD,omitempty"`
+	// MonthlySavings is what the change the finding recommends saves in USD, when the check knows it
+	MonthlySavings float64 `json:"monthlySavingsUSD,omitempty"`
+	// Attributes tell apart the findings a check reports for the same resource, such as the port
+	// of an open security group rule. They are part of the fingerprint, unlike the message.
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Fingerprint identifies the finding from one scan to the next, see fingerprint
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// Check is implemented by everything avm can run against an account. Run returns a Finding for
+// everything worth reporting and records its structured results in report. Severity and
+// Category are the defaults for the findings of the check; a finding may carry a different one.
+type Check interface {
+	ID() string
+	Service() string
+	Severity() Severity
+	Category() Category
+	Description() string
+	Run(ctx context.Context, clients *Clients, report *Report) ([]Finding, error)
+}
+
+// basicCheck is the Check used by the built-in checks, wrapping a plain function
+type basicCheck struct {
+	id          string
+	service     string
+	severity    Severity
+	category    Category
+	description string
+	// help explains why the findings matter and how to fix them
+	help string
+	// arn is the ARN of the resources the check reports, with {partition}, {region}, {account}
+	// and {id} standing for the parts taken from a finding
+	arn string
+	// optIn checks only run when selected explicitly, by ID or service
+	optIn bool
+	// global checks look at account-wide resources and run once rather than in every region
+	global bool
+	run    func(ctx context.Context, clients *Clients, report *Report) ([]Finding, error)
+}
+
+func (c *basicCheck) ID() string          { return c.id }
+func (c *basicCheck) Service() string     { return c.service }
+func (c *basicCheck) Severity() Severity  { return c.severity }
+func (c *basicCheck) Category() Category  { return c.category }
+func (c *basicCheck) Description() string { return c.description }
+func (c *basicCheck) Help() string        { return c.help }
+func (c *basicCheck) OptIn() bool         { return c.optIn }
+func (c *basicCheck) Global() bool        { return c.global }
+
+func (c *basicCheck) Run(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	return c.run(ctx, clients, report)
+}
+
+// ResourceARN returns the ARN of the resource of f, or "" if the check has no ARN format
+func (c *basicCheck) ResourceARN(f Finding) string {
+	if strings.HasPrefix(f.ResourceID, "arn:") {
+		return f.ResourceID
+	}
+	if c.arn == "" || f.ResourceID == "" {
+		return ""
+	}
+	region := f.Region
+	if region == globalRegion {
+		region = ""
+	}
+	return strings.NewReplacer(
+		"{partition}", partition(region),
+		"{region}", region,
+		"{account}", f.AccountID,
+		"{id}", f.ResourceID,
+	).Replace(c.arn)
+}
+
+// withTags returns the finding with the tags of its resource, see Finding.Tags
+func (f Finding) withTags(tags map[string]string) Finding {
+	f.Tags = tags
+	return f
+}
+
+// withMonthlyCost returns the finding with the estimated monthly cost of its resource, see Finding.MonthlyCost
+func (f Finding) withMonthlyCost(cost float64) Finding {
+	f.MonthlyCost = cost
+	return f
+}
+
+// withMonthlySavings returns the finding with what the change it recommends saves per month, see Finding.MonthlySavings
+func (f Finding) withMonthlySavings(savings float64) Finding {
+	f.MonthlySavings = savings
+	return f
+}
+
+// withAttribute returns the finding with one more attribute, see Finding.Attributes
+func (f Finding) withAttribute(name, value string) Finding {
+	attributes := make(map[string]string, len(f.Attributes)+1)
+	for k, v := range f.Attributes {
+		attributes[k] = v
+	}
+	attributes[name] = value
+	f.Attributes = attributes
+	return f
+}
+
+// finding fills in the check fields of a Finding
+func (c *basicCheck) finding(resourceID string, format string, args ...interface{}) Finding {
+	return Finding{
+		CheckID:    c.id,
+		Service:    c.service,
+		Severity:   c.severity,
+		Category:   c.category,
+		ResourceID: resourceID,
+		Message:    fmt.Sprintf(format, args...),
+	}
+}
+
+var (
+	registryMu sync.Mutex
+	registry   []Check
+)
+
+// registerCheck adds a check to the registry. Checks register themselves from init functions
+// next to the code they wrap, so adding a check never requires editing main.
+func registerCheck(c Check) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, existing := range registry {
+		if existing.ID() == c.ID() {
+			panic(fmt.Sprintf("check %q registered twice", c.ID()))
+		}
+	}
+	registry = append(registry, c)
+}
+
+// registeredChecks returns every registered check, sorted by service and then ID
+func registeredChecks() []Check {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	checks := make([]Check, len(registry))
+	copy(checks, registry)
+	sort.SliceStable(checks, func(i, j int) bool {
+		if checks[i].Service() != checks[j].Service() {
+			return checks[i].Service() < checks[j].Service()
+		}
+		return checks[i].ID() < checks[j].ID()
+	})
+	return checks
+}
+
+func isOptIn(c Check) bool {
+	optIn, ok := c.(interface{ OptIn() bool })
+	return ok && optIn.OptIn()
+}
+
+// checkHelp returns the help text of c, or its description if it has none
+func checkHelp(c Check) string {
+	if help, ok := c.(interface{ Help() string }); ok && help.Help() != "" {
+		return help.Help()
+	}
+	return c.Description()
+}
+
+func resourceARN(c Check, f Finding) string {
+	if arn, ok := c.(interface{ ResourceARN(f Finding) string }); ok {
+		return arn.ResourceARN(f)
+	}
+	return ""
+}
+
+// lookupCheck returns the registered check with the given ID, or nil
+func lookupCheck(id string) Check {
+	for _, c := range registeredChecks() {
+		if c.ID() == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func isGlobal(c Check) bool {
+	global, ok := c.(interface{ Global() bool })
+	return ok && global.Global()
+}
+
+func checkServices() []string {
+	seen := make(map[string]bool)
+	var services []string
+	for _, c := range registeredChecks() {
+		if !seen[c.Service()] {
+			seen[c.Service()] = true
+			services = append(services, c.Service())
+		}
+	}
+	return services
+}
+
+// selectChecks resolves the --checks and --disable lists. Both accept check IDs or service
+// names; an empty enable list selects every check that is not opt-in.
+func selectChecks(enable, disable []string) ([]Check, error) {
+	all := registeredChecks()
+	matches := func(c Check, name string) bool {
+		return name == "all" || name == c.ID() || name == c.Service()
+	}
+	known := func(name string) bool {
+		for _, c := range all {
+			if matches(c, name) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, name := range append(append([]string{}, enable...), disable...) {
+		if !known(name) {
+			return nil, fmt.Errorf("unknown check or service %q, see 'avm checks list'", name)
+		}
+	}
+
+	var selected []Check
+	for _, c := range all {
+		on := len(enable) == 0 && !isOptIn(c)
+		for _, name := range enable {
+			on = on || matches(c, name)
+		}
+		for _, name := range disable {
+			if name == c.ID() || name == c.Service() {
+				on = false
+			}
+		}
+		if on {
+			selected = append(selected, c)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no checks selected")
+	}
+	return selected, nil
+}
+
+// checkResult is the outcome of running one check
+type checkResult struct {
+	Check    Check
+	Findings []Finding
+	// Suppressed are left out of Findings, Expired are in Findings too
+	Suppressed []SuppressedFinding
+	Expired    []SuppressedFinding
+	// Baselined are left out of Findings as they are in the --baseline file
+	Baselined []Finding
+	// Notes tell how the check ran, see Report.Note
+	Notes    []string
+	Err      error
+	Duration time.Duration
+}
+
+// runChecks runs checks concurrently, each holding one of slots while it runs, so that the
+// checks of every region scanned at the same time share one limit. onResult is called for every
+// check in the order given, as soon as that check and all before it are done.
+func runChecks(ctx context.Context, clients *Clients, report *Report, checks []Check, slots chan struct{}, onResult func(checkResult)) []checkResult {
+	results := make([]checkResult, len(checks))
+	done := make([]chan struct{}, len(checks))
+
+	for i := range checks {
+		done[i] = make(chan struct{})
+	}
+	// Start checks in order so the first ones are printed early
+	go func() {
+		for i, c := range checks {
+			slots <- struct{}{}
+			go func(i int, c Check) {
+				defer close(done[i])
+				defer func() { <-slots }()
+
+				start := time.Now()
+				findings, err := c.Run(ctx, clients, report)
+				for j := range findings {
+					findings[j].AccountID = report.accountID()
+					if findings[j].Region == "" {
+						findings[j].Region = report.region()
+					}
+					if findings[j].ResourceARN == "" {
+						findings[j].ResourceARN = resourceARN(c, findings[j])
+					}
+					findings[j].Fingerprint = fingerprint(findings[j])
+				}
+				reported, suppressed, expired := applySuppressions(report.suppressions, findings, time.Now())
+				reported, baselined := report.baseline.split(reported)
+				results[i] = checkResult{
+					Check:      c,
+					Findings:   reported,
+					Suppressed: suppressed,
+					Expired:    expired,
+					Baselined:  baselined,
+					Notes:      report.notes(c.ID()),
+					Err:        err,
+					Duration:   time.Since(start),
+				}
+			}(i, c)
+		}
+	}()
+
+	for i := range checks {
+		<-done[i]
+		report.addResults(results[i])
+		if onResult != nil {
+			onResult(results[i])
+		}
+	}
+	return results
+}
+
+// printCheckResult prints a check the way avm always has: a ✅ when there is nothing to
+// report, otherwise one line per finding
+func printCheckResult(w io.Writer, result checkResult) {
+	c := result.Check
+	if result.Err != nil {
+		fmt.Fprintf(w, "\n%s: failed to run check: %v\n", c.Description(), result.Err)
+		return
+	}
+	var left []string
+	if len(result.Suppressed) > 0 {
+		left = append(left, fmt.Sprintf("%d suppressed", len(result.Suppressed)))
+	}
+	if len(result.Baselined) > 0 {
+		left = append(left, fmt.Sprintf("%d in the baseline", len(result.Baselined)))
+	}
+	leftOut := ""
+	if len(left) > 0 {
+		leftOut = " (" + strings.Join(left, ", ") + ")"
+	}
+	if len(result.Findings) == 0 {
+		fmt.Fprintf(w, "\n%s: none found ✅%s\n", c.Description(), leftOut)
+	} else {
+		fmt.Fprintf(w, "\n#### %s ####%s\n", c.Description(), leftOut)
+		for _, f := range result.Findings {
+			fmt.Fprintf(w, "%s [%s] %s\n", severityMarker(f.Severity), f.Severity, f.Message)
+		}
+		for _, e := range result.Expired {
+			fmt.Fprintf(w, "⚠️  The suppression of %s expired on %s and it is reported again (%s)\n", e.Finding.ResourceID, e.Suppression.Expires, e.Suppression.Reason)
+		}
+	}
+	for _, note := range result.Notes {
+		fmt.Fprintf(w, "ℹ️  %s\n", note)
+	}
+}
+
+func severityMarker(severity Severity) string {
+	switch severity {
+	case SeverityCritical, SeverityHigh:
+		return "❌"
+	case SeverityMedium, SeverityLow:
+		return "⚠️ "
+	default:
+		return "  -"
+	}
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
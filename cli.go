@@ -0,0 +1,551 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/mattn/go-isatty"
+)
+
+// regionNames is the list of regions offered by the interactive region prompt
+var regionNames = []string{
+	"us-east-1",
+	"us-east-2",
+	"us-west-1",
+	"us-west-2",
+	"af-south-1",
+	"ap-east-1",
+	"ap-south-1",
+	"ap-northeast-3",
+	"ap-northeast-2",
+	"ap-southeast-1",
+	"ap-southeast-2",
+	"ca-central-1",
+	"eu-central-1",
+	"eu-west-1",
+	"eu-west-2",
+	"eu-south-1",
+	"eu-west-3",
+	"eu-north-1",
+	"me-south-1",
+	"sa-east-1",
+}
+
+// regionPattern matches region codes such as eu-west-1 or us-gov-east-1 without pinning the list of regions
+var regionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-\d+$`)
+
+const allRegions = "all"
+
+// organizationAccounts is the --accounts value that scans every active account of the organisation
+const organizationAccounts = "organization"
+
+var (
+	accountIDPattern = regexp.MustCompile(`^\d{12}$`)
+	// sessionNamePattern is what STS accepts as a role session name
+	sessionNamePattern = regexp.MustCompile(`^[\w+=,.@-]{2,64}$`)
+)
+
+// allRegionsOption is how allRegions is offered in the interactive region prompt
+const allRegionsOption = "all enabled regions"
+
+const (
+	defaultConcurrency  = 4
+	defaultSessionName  = "avm"
+	defaultMaxRetries   = 3
+	defaultCPUThreshold = 20
+	defaultTimeframe    = 3 * 24 * time.Hour
+	// defaultSnapshotRate keeps DescribeSnapshotAttribute calls under the EC2 API rate limit
+	defaultSnapshotRate = 20
+	// maxSnapshotRate is far above what the EC2 API allows, and keeps the rate a usable interval
+	maxSnapshotRate = 1000
+	// CloudWatch keeps hourly datapoints for 63 days
+	maxTimeframe = 63 * 24 * time.Hour
+	// minTimeframe is one hourly datapoint, the shortest period metrics are read with
+	minTimeframe = time.Hour
+)
+
+// scanConfig holds every option that used to be collected through survey prompts
+type scanConfig struct {
+	// Region is the home region, used for account-wide calls and global checks
+	Region string
+	// Regions are scanned one by one; allRegions means every region enabled for the account
+	Regions []string
+	// Accounts are scanned one after the other by assuming Role in each. Empty means only the
+	// account of the credentials in use; organizationAccounts means every account in the organisation.
+	Accounts    []string
+	Role        string
+	ExternalID  string
+	SessionName string
+	// Profile, MaxRetries and EndpointURL apply to every AWS client, see clientFactory
+	Profile      string
+	MaxRetries   int
+	EndpointURL  string
+	Checks       []Check
+	Concurrency  int
+	CPUThreshold int
+	Timeframe    time.Duration
+	// SnapshotSample checks only this many random snapshots per region, all when 0
+	SnapshotSample int
+	// SnapshotRate is how many snapshots are checked per second at most
+	SnapshotRate float64
+	// SnapshotAge is how many days old a snapshot no AMI uses has to be to be reported
+	SnapshotAge int
+	// AMIUnusedDays is how many days an AMI no instance or launch template uses has to be unused
+	// to be reported
+	AMIUnusedDays int
+	// TrustedAccounts are the accounts snapshots and AMIs may be shared with
+	TrustedAccounts []string
+	Output          string
+	// OutFile is where the report is written, stdout when empty
+	OutFile string
+	// Suppressions waive accepted findings, see loadSuppressions
+	Suppressions []Suppression
+	// EBSPrices replaces the built-in EBS price table when set, see loadEBSPrices
+	EBSPrices *ebsPriceTable
+	// Baseline leaves out the findings accepted in a baseline file, if set
+	Baseline *Baseline
+	// FailOn makes the scan fail when there are findings of this severity or above, if set
+	FailOn Severity
+	// History is the database every scan is saved to, see historyStore; empty saves nothing
+	History string
+	// SecurityHub imports the findings of every account into its Security Hub, see exportToSecurityHub
+	SecurityHub bool
+	// Upload tells where to upload the reports of every account and region, if anywhere
+	Upload uploadOptions
+	// Notify tells where to post a summary of the scan, see newNotifiers
+	Notify notifyOptions
+
+	// progress counts the checks of the scan as they finish, when set
+	progress *scanProgress
+	// organizationScope only lets Accounts name active accounts of the organisation, for the
+	// scans requested through the API of avm serve --accounts organization
+	organizationScope bool
+}
+
+const usageText = `Usage:
+  avm                 run interactively (stdin must be a terminal)
+  avm scan [flags]    run a scan without any prompts
+  avm checks list     list the checks avm can run
+  avm baseline create [flags]
+                      run a scan and write its findings as a baseline for --baseline
+  avm serve --schedule "0 6 * * *" [flags]
+                      keep running and scan on a cron schedule
+  avm history         list past scans with their findings count
+  avm diff [FROM [TO]]
+                      show new, resolved and persisting findings between two scans
+
+Flags:
+`
+
+// runCommand runs the command given on the command line. With no arguments and a terminal on
+// stdin the user is prompted for a scan, exactly as before flags existed.
+func runCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		if isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd()) {
+			cfg, err := promptScanConfig()
+			if err != nil {
+				return err
+			}
+			return runScan(ctx, cfg)
+		}
+	} else if args[0] == "scan" {
+		args = args[1:]
+	} else if args[0] == "checks" {
+		return runChecksCommand(args[1:])
+	} else if args[0] == "baseline" {
+		return runBaselineCommand(ctx, args[1:])
+	} else if args[0] == "serve" {
+		return runServeCommand(ctx, args[1:])
+	} else if args[0] == "history" {
+		return runHistoryCommand(args[1:])
+	} else if args[0] == "diff" {
+		return runDiffCommand(args[1:])
+	} else if !strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("unknown command %q, see 'avm --help'", args[0])
+	}
+	cfg, err := parseScanFlags(args, usageText)
+	if err != nil {
+		return err
+	}
+	return runScan(ctx, cfg)
+}
+
+func runChecksCommand(args []string) error {
+	if len(args) != 1 || args[0] != "list" {
+		return errors.New("usage: avm checks list")
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tSERVICE\tSEVERITY\tCATEGORY\tDEFAULT\tDESCRIPTION")
+	for _, c := range registeredChecks() {
+		enabled := "on"
+		if isOptIn(c) {
+			enabled = "opt-in"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", c.ID(), c.Service(), c.Severity(), c.Category(), enabled, c.Description())
+	}
+	return writer.Flush()
+}
+
+func parseScanFlags(args []string, usage string) (*scanConfig, error) {
+	fs := flag.NewFlagSet("avm scan", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	buildConfig := addScanFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	return buildConfig()
+}
+
+// addScanFlags defines the flags of 'avm scan' on fs, so that other commands running scans can
+// take them too. The returned function builds the scanConfig once fs is parsed.
+func addScanFlags(fs *flag.FlagSet) func() (*scanConfig, error) {
+	region := fs.String("region", defaultRegion(), "AWS region to scan (defaults to AWS_REGION or AWS_DEFAULT_REGION)")
+	regions := fs.String("regions", "", "comma separated regions to scan, or 'all' for every region enabled for the account; --region is then only used for account-wide calls")
+	accounts := fs.String("accounts", "", "comma separated account IDs to scan, or 'organization' for every active account listed by AWS Organizations (default: the account of the credentials in use)")
+	role := fs.String("role", "", "name of the role to assume in every account given with --accounts, e.g. OrganizationAccountAccessRole")
+	externalID := fs.String("external-id", "", "external ID to pass when assuming --role")
+	sessionName := fs.String("session-name", defaultSessionName, "session name to use when assuming --role")
+	profile := fs.String("profile", "", "shared config profile to use (defaults to AWS_PROFILE)")
+	maxRetries := fs.Int("max-retries", defaultMaxRetries, "number of times a throttled or failed AWS call is retried")
+	endpointURL := fs.String("endpoint-url", os.Getenv("AWS_ENDPOINT_URL"), "send every AWS call to this URL instead, e.g. http://localhost:4566 for LocalStack (defaults to AWS_ENDPOINT_URL)")
+	checks := fs.String("checks", "", "comma separated check IDs or services to run, or 'all' (default: every check not marked opt-in)\nservices: "+strings.Join(checkServices(), ", ")+"; see 'avm checks list' for check IDs")
+	disable := fs.String("disable", "", "comma separated check IDs or services not to run")
+	concurrency := fs.Int("concurrency", defaultConcurrency, "number of checks to run at the same time, across every region")
+	cpuThreshold := fs.Int("cpu-threshold", defaultCPUThreshold, "average CPU percentage below which an EC2 instance is reported as underutilized")
+	timeframe := fs.String("timeframe", "3d", "period to average EC2 CPU usage and find the peak IOPS of io1 and io2 volumes over, e.g. 7d or 36h")
+	sample := fs.Int("sample", 0, "check the sharing of only this many random EBS snapshots per region (default: every snapshot)")
+	snapshotAge := fs.Int("snapshot-age", defaultSnapshotAge, "days after which a snapshot no AMI uses is reported as old")
+	amiUnusedDays := fs.Int("ami-unused-days", defaultAMIUnusedDays, "days after which an AMI no instance or launch template uses is reported as unused")
+	trustedAccounts := fs.String("trusted-accounts", "", "comma separated account IDs snapshots and AMIs may be shared with")
+	ebsPrices := fs.String("ebs-prices", "", "JSON file of EBS prices to estimate costs with instead of the built-in table, in the format of ebs-prices.json")
+	snapshotRate := fs.Float64("snapshot-rate", defaultSnapshotRate, "most EBS snapshots whose sharing is checked per second, per region")
+	out := fs.String("out", "", "write the report to this file instead of stdout")
+	suppressions := fs.String("suppressions", "", "YAML file of accepted findings to leave out of the results and of --fail-on")
+	baseline := fs.String("baseline", "", "baseline file written by 'avm baseline create'; only findings that are not in it are reported and count towards --fail-on")
+	failOn := fs.String("fail-on", "", "exit with status 1 when there are findings of this severity or above: critical, high, medium, low or info")
+	output := fs.String("output", "text", "output format: "+strings.Join(outputFormatNames(), ", "))
+	history := fs.String("history", defaultHistoryPath(), "history database the scan is saved to, for 'avm history' and 'avm diff'")
+	noHistory := fs.Bool("no-history", false, "do not save the scan to the history database")
+	upload := fs.String("upload", "", "upload a report of every account and region to s3://bucket/prefix/, under <account>/<region>/<timestamp>/")
+	uploadFormats := fs.String("upload-formats", "json,html,csv", "comma separated formats of the uploaded reports: json, html, csv, markdown, sarif or junit")
+	uploadKMSKey := fs.String("upload-kms-key", "", "KMS key ID, ARN or alias to encrypt the uploaded reports with (SSE-KMS)")
+	securityHub := fs.Bool("security-hub", false, "import the findings into Security Hub and archive the ones imported before that are no longer found")
+	slackWebhook := fs.String("slack-webhook", os.Getenv("AVM_SLACK_WEBHOOK"), "Slack incoming webhook URL to post a summary of the scan to (defaults to AVM_SLACK_WEBHOOK)")
+	webhook := fs.String("webhook", os.Getenv("AVM_WEBHOOK"), "URL to post a summary of the scan to as JSON, see the README for the payload (defaults to AVM_WEBHOOK)")
+	notifyOnlyNew := fs.Bool("notify-only-new", false, "only notify about findings the previous scan in the history did not have")
+	notifySeverity := fs.String("notify-severity", "", "only notify about findings of this severity or above: critical, high, medium, low or info")
+	notifyTop := fs.Int("notify-top", defaultNotifyTop, "number of findings listed in a notification, most severe first")
+
+	return func() (*scanConfig, error) {
+		cfg := &scanConfig{
+			Region:          *region,
+			Regions:         splitList(*regions),
+			Accounts:        splitList(*accounts),
+			Role:            *role,
+			ExternalID:      *externalID,
+			SessionName:     *sessionName,
+			Profile:         *profile,
+			MaxRetries:      *maxRetries,
+			EndpointURL:     *endpointURL,
+			OutFile:         *out,
+			Concurrency:     *concurrency,
+			CPUThreshold:    *cpuThreshold,
+			SnapshotSample:  *sample,
+			SnapshotRate:    *snapshotRate,
+			SnapshotAge:     *snapshotAge,
+			AMIUnusedDays:   *amiUnusedDays,
+			TrustedAccounts: splitList(*trustedAccounts),
+			Output:          *output,
+			History:         *history,
+			SecurityHub:     *securityHub,
+			Notify: notifyOptions{
+				SlackWebhook: *slackWebhook,
+				Webhook:      *webhook,
+				OnlyNew:      *notifyOnlyNew,
+				Top:          *notifyTop,
+			},
+		}
+		if *noHistory {
+			cfg.History = ""
+		}
+		var err error
+		if cfg.Checks, err = selectChecks(splitList(*checks), splitList(*disable)); err != nil {
+			return nil, err
+		}
+		if cfg.Timeframe, err = parseTimeframe(*timeframe); err != nil {
+			return nil, err
+		}
+		if *suppressions != "" {
+			if cfg.Suppressions, err = loadSuppressions(*suppressions); err != nil {
+				return nil, err
+			}
+		}
+		if *ebsPrices != "" {
+			if cfg.EBSPrices, err = loadEBSPrices(*ebsPrices); err != nil {
+				return nil, err
+			}
+		}
+		if *baseline != "" {
+			if cfg.Baseline, err = loadBaseline(*baseline); err != nil {
+				return nil, err
+			}
+		}
+		if *failOn != "" {
+			if cfg.FailOn, err = parseSeverity(*failOn); err != nil {
+				return nil, fmt.Errorf("invalid --fail-on: %v", err)
+			}
+		}
+		if *upload != "" {
+			if cfg.Upload.Bucket, cfg.Upload.Prefix, err = parseS3URL(*upload); err != nil {
+				return nil, err
+			}
+			cfg.Upload.Formats = splitList(*uploadFormats)
+			cfg.Upload.KMSKey = *uploadKMSKey
+		}
+		if *notifySeverity != "" {
+			if cfg.Notify.MinSeverity, err = parseSeverity(*notifySeverity); err != nil {
+				return nil, fmt.Errorf("invalid --notify-severity: %v", err)
+			}
+		}
+		if err := cfg.validate(); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+}
+
+func (c *scanConfig) validate() error {
+	if c.Region == "" && len(c.Regions) > 0 {
+		c.Region = c.Regions[0]
+		if c.Region == allRegions {
+			c.Region = "us-east-1"
+		}
+	}
+	if c.Region == "" {
+		return errors.New("no region given, use --region or set AWS_REGION")
+	}
+	if len(c.Regions) == 0 {
+		c.Regions = []string{c.Region}
+	}
+	if contains(c.Regions, allRegions) && len(c.Regions) > 1 {
+		return errors.New("--regions all cannot be combined with other regions")
+	}
+	for _, region := range append([]string{c.Region}, c.Regions...) {
+		if region != allRegions && !regionPattern.MatchString(region) {
+			return fmt.Errorf("invalid region %q", region)
+		}
+	}
+	if contains(c.Accounts, organizationAccounts) && len(c.Accounts) > 1 {
+		return errors.New("--accounts organization cannot be combined with account IDs")
+	}
+	for _, account := range c.Accounts {
+		if account != organizationAccounts && !accountIDPattern.MatchString(account) {
+			return fmt.Errorf("invalid account ID %q, expected 12 digits", account)
+		}
+	}
+	if len(c.Accounts) > 0 && c.Role == "" {
+		return errors.New("--accounts needs --role, the role to assume in every account")
+	}
+	if c.Role != "" && !sessionNamePattern.MatchString(c.SessionName) {
+		return fmt.Errorf("invalid --session-name %q, use 2 to 64 letters, digits or +=,.@_-", c.SessionName)
+	}
+	if c.MaxRetries < 0 {
+		return fmt.Errorf("--max-retries must not be negative, got %d", c.MaxRetries)
+	}
+	if c.EndpointURL != "" {
+		if u, err := url.Parse(c.EndpointURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid --endpoint-url %q, expected a URL such as http://localhost:4566", c.EndpointURL)
+		}
+	}
+	if c.Concurrency < 1 {
+		return fmt.Errorf("--concurrency must be at least 1, got %d", c.Concurrency)
+	}
+	if c.CPUThreshold < 1 || c.CPUThreshold > 100 {
+		return fmt.Errorf("--cpu-threshold must be between 1 and 100, got %d", c.CPUThreshold)
+	}
+	if c.SnapshotSample < 0 {
+		return fmt.Errorf("--sample must not be negative, got %d", c.SnapshotSample)
+	}
+	if c.SnapshotAge < 1 {
+		return fmt.Errorf("--snapshot-age must be at least 1 day, got %d", c.SnapshotAge)
+	}
+	if c.AMIUnusedDays < 1 {
+		return fmt.Errorf("--ami-unused-days must be at least 1 day, got %d", c.AMIUnusedDays)
+	}
+	for _, account := range c.TrustedAccounts {
+		if !accountIDPattern.MatchString(account) {
+			return fmt.Errorf("invalid --trusted-accounts account ID %q, expected 12 digits", account)
+		}
+	}
+	if math.IsNaN(c.SnapshotRate) || c.SnapshotRate <= 0 || c.SnapshotRate > maxSnapshotRate {
+		return fmt.Errorf("--snapshot-rate must be more than 0 and at most %d, got %g", maxSnapshotRate, c.SnapshotRate)
+	}
+	if c.Timeframe < minTimeframe || c.Timeframe > maxTimeframe {
+		return fmt.Errorf("--timeframe must be between %s and %dd, got %s", strings.TrimSuffix(minTimeframe.String(), "0m0s"), int(maxTimeframe.Hours()/24), c.Timeframe)
+	}
+	if err := c.Upload.validate(); err != nil {
+		return err
+	}
+	if err := c.Notify.validate(); err != nil {
+		return err
+	}
+	if renderers[c.Output] == nil {
+		return fmt.Errorf("unknown output format %q, expected one of: %s", c.Output, strings.Join(outputFormatNames(), ", "))
+	}
+	return nil
+}
+
+func defaultRegion() string {
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// parseTimeframe accepts a number of days ("7"), days with a suffix ("7d") or any Go duration ("36h")
+func parseTimeframe(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	days := strings.TrimSuffix(value, "d")
+	if n, err := strconv.Atoi(days); err == nil {
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --timeframe %q, use a number of days such as 7d or a duration such as 36h", value)
+	}
+	return d, nil
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// promptScanConfig asks the same questions avm has always asked, up front, and returns the answers as a scanConfig
+func promptScanConfig() (*scanConfig, error) {
+	cfg := &scanConfig{
+		Concurrency:   defaultConcurrency,
+		CPUThreshold:  defaultCPUThreshold,
+		Timeframe:     defaultTimeframe,
+		SnapshotRate:  defaultSnapshotRate,
+		SnapshotAge:   defaultSnapshotAge,
+		AMIUnusedDays: defaultAMIUnusedDays,
+		Output:        "text",
+		SessionName:   defaultSessionName,
+		MaxRetries:    defaultMaxRetries,
+		EndpointURL:   os.Getenv("AWS_ENDPOINT_URL"),
+		History:       defaultHistoryPath(),
+	}
+
+	// Use the alec survey module to ask the user to select a region
+	prompt := &survey.Select{
+		Message: "Select a region:",
+		Options: append([]string{allRegionsOption}, regionNames...),
+	}
+	var selectedRegion string
+	if err := survey.AskOne(prompt, &selectedRegion); err != nil {
+		return nil, fmt.Errorf("failed to get user input: %v", err)
+	}
+	fmt.Println("Selected region:", selectedRegion)
+	if selectedRegion == allRegionsOption {
+		cfg.Regions = []string{allRegions}
+		cfg.Region = defaultRegion()
+	} else {
+		cfg.Region = selectedRegion
+	}
+
+	// The default checks run apart from the ones declined here. Every question covers the checks
+	// avm ran for it before checks could be selected, so checks added since are not declined
+	// with them.
+	var declined, accepted []string
+	optionalChecks := []struct {
+		checks  []string
+		message string
+	}{
+		{[]string{"trusted-advisor"}, "Do you want to run Trusted Advisor checks?"},
+		{[]string{"ec2-imdv1", "ec2-underutilized", "ec2-multiple-enis", "ec2-reserved-instances", "ec2-instance-types"}, "Do you want to run EC2 checks?"},
+		{[]string{"s3-storage-classes", "s3-lifecycle-policies"}, "Do you want to run S3 checks?"},
+		{[]string{"dynamodb-capacity-modes"}, "Do you want to run DynamoDB checks?"},
+	}
+	for _, optional := range optionalChecks {
+		answer := false
+		if err := survey.AskOne(&survey.Confirm{Message: optional.message}, &answer); err != nil {
+			return nil, fmt.Errorf("error with survey: %v", err)
+		}
+		if !answer {
+			declined = append(declined, optional.checks...)
+			continue
+		}
+		accepted = append(accepted, optional.checks...)
+
+		if contains(optional.checks, underutilizedCheck.id) {
+			cpuThresholdStr := ""
+			cpuThresholdPrompt := &survey.Select{
+				Message: "Identify Underutilized EC2s. Enter a CPU threshold (default 20%):",
+				Options: []string{"20", "30", "40", "50"},
+				Default: "20",
+			}
+			if err := survey.AskOne(cpuThresholdPrompt, &cpuThresholdStr); err != nil {
+				return nil, fmt.Errorf("error with survey: %v", err)
+			}
+			cfg.CPUThreshold, _ = strconv.Atoi(cpuThresholdStr)
+
+			timeframeStr := ""
+			timeframePrompt := &survey.Select{
+				Message: "Enter a timeframe (default 3 days):",
+				Options: []string{"1", "3", "7", "14"},
+				Default: "3",
+			}
+			if err := survey.AskOne(timeframePrompt, &timeframeStr); err != nil {
+				return nil, fmt.Errorf("error with survey: %v", err)
+			}
+			cfg.Timeframe, _ = parseTimeframe(timeframeStr)
+		}
+	}
+
+	// Ask user to display in JSON format
+	displayJSON := false
+	displayprompt := &survey.Confirm{
+		Message: "Do you want to display this information in JSON format?",
+	}
+	if err := survey.AskOne(displayprompt, &displayJSON); err != nil {
+		return nil, fmt.Errorf("error with survey: %v", err)
+	}
+	if displayJSON {
+		cfg.Output = "json"
+	}
+
+	var err error
+	if cfg.Checks, err = selectChecks(nil, declined); err != nil {
+		return nil, err
+	}
+	// Opt-in checks are not in the default set, they run only when accepted
+	for _, id := range accepted {
+		if c := lookupCheck(id); c != nil && isOptIn(c) {
+			cfg.Checks = append(cfg.Checks, c)
+		}
+	}
+
+	return cfg, cfg.validate()
+}
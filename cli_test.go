@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeframe(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "7d", want: 7 * 24 * time.Hour},
+		{value: "30d", want: 30 * 24 * time.Hour},
+		{value: "14", want: 14 * 24 * time.Hour},
+		{value: " 7d ", want: 7 * 24 * time.Hour},
+		{value: "36h", want: 36 * time.Hour},
+		{value: "90m", want: 90 * time.Minute},
+		{value: "", wantErr: true},
+		{value: "d", wantErr: true},
+		{value: "a week", wantErr: true},
+		{value: "7days", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseTimeframe(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTimeframe(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseTimeframe(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudtrail"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/organizations"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/securityhub"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/support"
+)
+
+// Clients holds the AWS clients and user options handed to every check in a scan. Checks use
+// these clients rather than creating sessions of their own, so --profile, --max-retries and
+// --endpoint-url apply to every call.
+type Clients struct {
+	Region        string
+	Session       *session.Session
+	EC2           *ec2.EC2
+	ECR           *ecr.ECR
+	CloudWatch    *cloudwatch.CloudWatch
+	CloudTrail    *cloudtrail.CloudTrail
+	S3            *s3.S3
+	Lambda        *lambda.Lambda
+	RDS           *rds.RDS
+	IAM           *iam.IAM
+	STS           *sts.STS
+	DynamoDB      *dynamodb.DynamoDB
+	ServiceQuotas *servicequotas.ServiceQuotas
+	Organizations *organizations.Organizations
+	SecurityHub   *securityhub.SecurityHub
+	// Support is always created in the region the AWS Support API is served from
+	Support *support.Support
+
+	CPUThreshold   int
+	Timeframe      time.Duration
+	SnapshotSample int
+	SnapshotRate   float64
+	// SnapshotAge is how old a snapshot no AMI uses has to be to be reported
+	SnapshotAge time.Duration
+	// AMIUnusedAge is how long an AMI no instance or launch template uses has to be unused to be
+	// reported
+	AMIUnusedAge time.Duration
+	// TrustedAccounts may be shared snapshots and AMIs with
+	TrustedAccounts []string
+	// EBSPrices estimate the cost of volumes and snapshots
+	EBSPrices *ebsPriceTable
+
+	factory *clientFactory
+}
+
+// clientFactory creates every AWS session avm uses
+type clientFactory struct {
+	cfg *scanConfig
+}
+
+func newClientFactory(cfg *scanConfig) *clientFactory {
+	return &clientFactory{cfg: cfg}
+}
+
+// session creates a session for region. creds are the credentials of an assumed role, or nil
+// for the credentials of --profile or the default credential chain.
+func (f *clientFactory) session(region string, creds *credentials.Credentials) (*session.Session, error) {
+	config := aws.Config{
+		Region:      aws.String(region),
+		Credentials: creds,
+		MaxRetries:  aws.Int(f.cfg.MaxRetries),
+	}
+	if f.cfg.EndpointURL != "" {
+		// Stand-ins such as LocalStack serve every service from one URL and do not support
+		// virtual hosted S3 buckets
+		config.Endpoint = aws.String(f.cfg.EndpointURL)
+		config.S3ForcePathStyle = aws.Bool(true)
+	}
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            config,
+		Profile:           f.cfg.Profile,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %v", err)
+	}
+	return sess, nil
+}
+
+// clients creates the clients for region, see session
+func (f *clientFactory) clients(region string, creds *credentials.Credentials) (*Clients, error) {
+	sess, err := f.session(region, creds)
+	if err != nil {
+		return nil, err
+	}
+	prices := f.cfg.EBSPrices
+	if prices == nil {
+		prices = defaultEBSPrices
+	}
+	return &Clients{
+		Region:          region,
+		Session:         sess,
+		EC2:             ec2.New(sess),
+		ECR:             ecr.New(sess),
+		CloudWatch:      cloudwatch.New(sess),
+		CloudTrail:      cloudtrail.New(sess),
+		S3:              s3.New(sess),
+		Lambda:          lambda.New(sess),
+		RDS:             rds.New(sess),
+		IAM:             iam.New(sess),
+		STS:             sts.New(sess),
+		DynamoDB:        dynamodb.New(sess),
+		ServiceQuotas:   servicequotas.New(sess),
+		Organizations:   organizations.New(sess),
+		SecurityHub:     securityhub.New(sess),
+		Support:         support.New(sess, aws.NewConfig().WithRegion(supportRegion(region))),
+		CPUThreshold:    f.cfg.CPUThreshold,
+		Timeframe:       f.cfg.Timeframe,
+		SnapshotSample:  f.cfg.SnapshotSample,
+		SnapshotRate:    f.cfg.SnapshotRate,
+		SnapshotAge:     time.Duration(f.cfg.SnapshotAge) * 24 * time.Hour,
+		AMIUnusedAge:    time.Duration(f.cfg.AMIUnusedDays) * 24 * time.Hour,
+		TrustedAccounts: f.cfg.TrustedAccounts,
+		EBSPrices:       prices,
+		factory:         f,
+	}, nil
+}
+
+// forRegion returns clients for region with the same credentials as c
+func (c *Clients) forRegion(region string) (*Clients, error) {
+	if region == c.Region {
+		return c, nil
+	}
+	return c.factory.clients(region, c.Session.Config.Credentials)
+}
+
+// withCredentials returns clients for the same region as c using creds
+func (c *Clients) withCredentials(creds *credentials.Credentials) (*Clients, error) {
+	return c.factory.clients(c.Region, creds)
+}
+
+// supportRegion is the region the AWS Support API is served from in the partition of region
+func supportRegion(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "cn-north-1"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "us-gov-west-1"
+	default:
+		return "us-east-1"
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+var csvHeader = []string{"account", "region", "service", "check_id", "resource_id", "severity", "message", "estimated_monthly_cost_usd", "estimated_monthly_savings_usd"}
+
+// formatMonthlyCost leaves the cost or savings empty for findings without them, so that they are
+// not read as free
+func formatMonthlyCost(cost float64) string {
+	if cost == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(cost, 'f', 2, 64)
+}
+
+// renderCSV writes one row per finding, in the order they were reported
+func renderCSV(w io.Writer, report ScanReport) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return fmt.Errorf("error with writing csv data: %v", err)
+	}
+	for _, f := range report.findings() {
+		record := []string{
+			f.AccountID,
+			f.Region,
+			f.Service,
+			f.CheckID,
+			f.ResourceID,
+			string(f.Severity),
+			f.Message,
+			formatMonthlyCost(f.MonthlyCost),
+			formatMonthlyCost(f.MonthlySavings),
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("error with writing csv data: %v", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("error with writing csv data: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// findingsDiff is the outcome of comparing the findings of two runs
+type findingsDiff struct {
+	From       historyRun `json:"from"`
+	To         historyRun `json:"to"`
+	New        []Finding  `json:"new"`
+	Resolved   []Finding  `json:"resolved"`
+	Persisting []Finding  `json:"persisting"`
+	// ScopeDiffers is set when the runs did not run the same checks without error in the same
+	// accounts and regions; OutOfScope counts the findings of either run that were left out
+	ScopeDiffers bool `json:"scopeDiffers"`
+	OutOfScope   int  `json:"outOfScope"`
+}
+
+// diffReports compares the findings of two runs, only for the checks that ran without error in
+// both, by account and region. A narrower run, or a check that failed once, then does not show
+// the findings it did not look for as resolved, nor as new in the next run.
+func diffReports(from, to ScanReport) findingsDiff {
+	fromScope, toScope := from.completedScope(), to.completedScope()
+	common := make(map[scopeKey]bool)
+	for key := range fromScope {
+		if toScope[key] {
+			common[key] = true
+		}
+	}
+	var diff findingsDiff
+	diff.ScopeDiffers = len(common) != len(fromScope) || len(common) != len(toScope)
+	fromFindings, fromLeft := from.scopedFindings(common)
+	toFindings, toLeft := to.scopedFindings(common)
+	diff.OutOfScope = fromLeft + toLeft
+	diff.New, diff.Resolved, diff.Persisting = diffFindings(fromFindings, toFindings)
+	return diff
+}
+
+// diffFindings compares the findings of two runs by fingerprint. Findings with the same fingerprint
+// are matched by message first; the ones left over are paired up as persisting, as their message
+// may hold figures that change from run to run. Persisting findings are the ones of the later run.
+func diffFindings(from, to []Finding) (added, resolved, persisting []Finding) {
+	before := make(map[string][]Finding)
+	for _, f := range from {
+		before[fingerprint(f)] = append(before[fingerprint(f)], f)
+	}
+	after := make(map[string][]Finding)
+	var keys []string
+	for _, f := range to {
+		key := fingerprint(f)
+		if _, ok := after[key]; !ok {
+			keys = append(keys, key)
+		}
+		after[key] = append(after[key], f)
+	}
+
+	for _, key := range keys {
+		old := before[key]
+		var unmatched []Finding
+		for _, f := range after[key] {
+			matched := false
+			for i, o := range old {
+				if o.Message == f.Message {
+					old = append(old[:i:i], old[i+1:]...)
+					matched = true
+					break
+				}
+			}
+			if matched {
+				persisting = append(persisting, f)
+			} else {
+				unmatched = append(unmatched, f)
+			}
+		}
+		for _, f := range unmatched {
+			if len(old) > 0 {
+				old = old[1:]
+				persisting = append(persisting, f)
+			} else {
+				added = append(added, f)
+			}
+		}
+		before[key] = old
+	}
+	// What is left of the earlier run was not reported again
+	for _, f := range from {
+		key := fingerprint(f)
+		if len(before[key]) > 0 {
+			resolved = append(resolved, before[key]...)
+			delete(before, key)
+		}
+	}
+	return added, resolved, persisting
+}
+
+const diffUsageText = `Usage:
+  avm diff [flags] [FROM [TO]]
+
+Compares the findings of two runs listed by 'avm history'. Without runs the last two are
+compared; with only FROM it is compared with the last run.
+
+Flags:
+`
+
+func runDiffCommand(args []string) error {
+	fs := flag.NewFlagSet("avm diff", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), diffUsageText)
+		fs.PrintDefaults()
+	}
+	historyPath := fs.String("history", defaultHistoryPath(), "history database the runs are read from")
+	output := fs.String("output", "text", "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 2 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args()[2:], " "))
+	}
+	if *output != "text" && *output != "json" {
+		return fmt.Errorf("unknown output format %q, expected one of: text, json", *output)
+	}
+	if *historyPath == "" {
+		return fmt.Errorf("no history database, use --history")
+	}
+
+	history, err := openHistory(*historyPath)
+	if err != nil {
+		return err
+	}
+	defer history.Close()
+
+	var ids []uint64
+	for _, arg := range fs.Args() {
+		id, err := parseRunID(arg)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) < 2 {
+		runs, err := history.Runs(2)
+		if err != nil {
+			return err
+		}
+		if len(runs) < 2-len(ids) {
+			return errNoHistory
+		}
+		if len(ids) == 0 {
+			ids = []uint64{runs[1].ID, runs[0].ID}
+		} else {
+			ids = append(ids, runs[0].ID)
+		}
+	}
+
+	from, fromReport, err := history.Report(ids[0])
+	if err != nil {
+		return err
+	}
+	to, toReport, err := history.Report(ids[1])
+	if err != nil {
+		return err
+	}
+	diff := diffReports(fromReport, toReport)
+	diff.From, diff.To = from, to
+
+	if *output == "json" {
+		jsonData, err := json.MarshalIndent(diff, "", "    ")
+		if err != nil {
+			return fmt.Errorf("error with parsing json data: %v", err)
+		}
+		fmt.Println(string(jsonData))
+		return nil
+	}
+	renderDiff(os.Stdout, diff)
+	return nil
+}
+
+// sortFindings orders findings by severity, most severe first, then by check and resource
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Severity != b.Severity {
+			return a.Severity.rank() > b.Severity.rank()
+		}
+		if a.CheckID != b.CheckID {
+			return a.CheckID < b.CheckID
+		}
+		return a.ResourceID < b.ResourceID
+	})
+}
+
+func renderDiff(w io.Writer, diff findingsDiff) {
+	fmt.Fprintf(w, "Comparing run %d (%s) with run %d (%s)\n", diff.From.ID, diff.From.Time.Local().Format("2006-01-02 15:04"),
+		diff.To.ID, diff.To.Time.Local().Format("2006-01-02 15:04"))
+	if diff.ScopeDiffers {
+		fmt.Fprintf(w, "⚠️  The runs did not cover the same checks, accounts and regions, or a check failed in one of them. "+
+			"Only the checks that ran without error in both are compared, %d findings are left out.\n", diff.OutOfScope)
+	}
+	sections := []struct {
+		title    string
+		marker   string
+		findings []Finding
+	}{
+		{"New findings", "🆕", diff.New},
+		{"Resolved findings", "✅", diff.Resolved},
+		{"Persisting findings", "⏳", diff.Persisting},
+	}
+	for _, section := range sections {
+		fmt.Fprintf(w, "\n######## %s: %d ########\n", section.title, len(section.findings))
+		sortFindings(section.findings)
+		for _, f := range section.findings {
+			fmt.Fprintf(w, "%s [%s] %s %s/%s: %s\n", section.marker, f.Severity, f.CheckID, f.AccountID, f.Region, f.Message)
+		}
+	}
+}
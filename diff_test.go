@@ -0,0 +1,169 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func messages(findings []Finding) []string {
+	var list []string
+	for _, f := range findings {
+		list = append(list, f.Message)
+	}
+	return list
+}
+
+func TestDiffFindings(t *testing.T) {
+	cpu := func(message string) Finding {
+		return Finding{CheckID: "ec2-underutilized", AccountID: "111111111111", Region: "us-east-1", ResourceID: "i-1", Message: message}
+	}
+	open := func(id string) Finding {
+		return Finding{CheckID: "sg-open-to-world", AccountID: "111111111111", Region: "us-east-1", ResourceID: id, Message: id + " is open"}
+	}
+	tests := []struct {
+		name                        string
+		from, to                    []Finding
+		added, resolved, persisting []string
+	}{
+		{
+			name: "empty runs",
+		},
+		{
+			name:  "every finding new",
+			to:    []Finding{open("sg-1"), open("sg-2")},
+			added: []string{"sg-1 is open", "sg-2 is open"},
+		},
+		{
+			name:     "every finding resolved",
+			from:     []Finding{open("sg-1")},
+			resolved: []string{"sg-1 is open"},
+		},
+		{
+			name:       "new, resolved and persisting",
+			from:       []Finding{open("sg-1"), open("sg-2")},
+			to:         []Finding{open("sg-2"), open("sg-3")},
+			added:      []string{"sg-3 is open"},
+			resolved:   []string{"sg-1 is open"},
+			persisting: []string{"sg-2 is open"},
+		},
+		{
+			name:       "message with changed figures persists",
+			from:       []Finding{cpu("average CPU 3%")},
+			to:         []Finding{cpu("average CPU 4%")},
+			persisting: []string{"average CPU 4%"},
+		},
+		{
+			name:       "same fingerprint matched by message first",
+			from:       []Finding{cpu("a"), cpu("b")},
+			to:         []Finding{cpu("c"), cpu("b"), cpu("d")},
+			added:      []string{"d"},
+			persisting: []string{"b", "c"},
+		},
+		{
+			name:     "attributes are part of the fingerprint",
+			from:     []Finding{{CheckID: "ebs-volume-optimization", ResourceID: "vol-1", Attributes: map[string]string{"issue": "gp2"}, Message: "gp2"}},
+			to:       []Finding{{CheckID: "ebs-volume-optimization", ResourceID: "vol-1", Attributes: map[string]string{"issue": "iops"}, Message: "iops"}},
+			added:    []string{"iops"},
+			resolved: []string{"gp2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, resolved, persisting := diffFindings(tt.from, tt.to)
+			if got := messages(added); !reflect.DeepEqual(got, tt.added) {
+				t.Errorf("added = %q, want %q", got, tt.added)
+			}
+			if got := messages(resolved); !reflect.DeepEqual(got, tt.resolved) {
+				t.Errorf("resolved = %q, want %q", got, tt.resolved)
+			}
+			if got := messages(persisting); !reflect.DeepEqual(got, tt.persisting) {
+				t.Errorf("persisting = %q, want %q", got, tt.persisting)
+			}
+		})
+	}
+}
+
+func TestDiffReports(t *testing.T) {
+	region := func(code string, checksRun []string, errors map[string]string, results ...Finding) MasterStructure {
+		return MasterStructure{
+			AccountInformation: AccountInformation{AccountId: "111111111111", RegionCode: code},
+			ChecksRun:          checksRun,
+			Errors:             errors,
+			Results:            results,
+		}
+	}
+	report := func(regions ...MasterStructure) ScanReport {
+		return ScanReport{Accounts: []AccountReport{{AccountId: "111111111111", Regions: regions}}}
+	}
+	finding := func(check, region, id string) Finding {
+		return Finding{CheckID: check, AccountID: "111111111111", Region: region, ResourceID: id, Message: id}
+	}
+	checks := []string{"sg-open-to-world", "ebs-orphaned-volumes"}
+
+	tests := []struct {
+		name                        string
+		from, to                    ScanReport
+		added, resolved, persisting []string
+		scopeDiffers                bool
+		outOfScope                  int
+	}{
+		{
+			name: "same scope",
+			from: report(region("us-east-1", checks, nil, finding("sg-open-to-world", "us-east-1", "sg-1"))),
+			to: report(region("us-east-1", checks, nil,
+				finding("sg-open-to-world", "us-east-1", "sg-1"), finding("ebs-orphaned-volumes", "us-east-1", "vol-1"))),
+			added:      []string{"vol-1"},
+			persisting: []string{"sg-1"},
+		},
+		{
+			name: "region left out of the later run",
+			from: report(
+				region("us-east-1", checks, nil, finding("sg-open-to-world", "us-east-1", "sg-1")),
+				region("eu-west-1", checks, nil, finding("sg-open-to-world", "eu-west-1", "sg-2"))),
+			to:           report(region("us-east-1", checks, nil, finding("sg-open-to-world", "us-east-1", "sg-1"))),
+			persisting:   []string{"sg-1"},
+			scopeDiffers: true,
+			outOfScope:   1,
+		},
+		{
+			name: "check failed in the later run",
+			from: report(region("us-east-1", checks, nil,
+				finding("sg-open-to-world", "us-east-1", "sg-1"), finding("ebs-orphaned-volumes", "us-east-1", "vol-1"))),
+			to: report(region("us-east-1", checks, map[string]string{"ebs-orphaned-volumes": "access denied"},
+				finding("sg-open-to-world", "us-east-1", "sg-2"))),
+			added:        []string{"sg-2"},
+			resolved:     []string{"sg-1"},
+			scopeDiffers: true,
+			outOfScope:   1,
+		},
+		{
+			name: "check not run in the earlier run",
+			from: report(region("us-east-1", checks[:1], nil)),
+			to: report(region("us-east-1", checks, nil,
+				finding("sg-open-to-world", "us-east-1", "sg-1"), finding("ebs-orphaned-volumes", "us-east-1", "vol-1"))),
+			added:        []string{"sg-1"},
+			scopeDiffers: true,
+			outOfScope:   1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := diffReports(tt.from, tt.to)
+			if got := messages(diff.New); !reflect.DeepEqual(got, tt.added) {
+				t.Errorf("new = %q, want %q", got, tt.added)
+			}
+			if got := messages(diff.Resolved); !reflect.DeepEqual(got, tt.resolved) {
+				t.Errorf("resolved = %q, want %q", got, tt.resolved)
+			}
+			if got := messages(diff.Persisting); !reflect.DeepEqual(got, tt.persisting) {
+				t.Errorf("persisting = %q, want %q", got, tt.persisting)
+			}
+			if diff.ScopeDiffers != tt.scopeDiffers {
+				t.Errorf("scopeDiffers = %v, want %v", diff.ScopeDiffers, tt.scopeDiffers)
+			}
+			if diff.OutOfScope != tt.outOfScope {
+				t.Errorf("outOfScope = %d, want %d", diff.OutOfScope, tt.outOfScope)
+			}
+		})
+	}
+}
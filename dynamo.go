@@ -1,24 +1,25 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log"
 	"math"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
-func printdynamoTableStats(svc *dynamodb.DynamoDB) error {
+// getDynamoTableStats counts the tables in the region by capacity mode
+func getDynamoTableStats(ctx context.Context, svc *dynamodb.DynamoDB) (DynamoDb, error) {
 
 	// Get the list of table names
 	input := &dynamodb.ListTablesInput{}
 	tables := make([]string, 0)
 
 	for len(tables) < 500 {
-		result, err := svc.ListTables(input)
+		result, err := svc.ListTablesWithContext(ctx, input)
 		if err != nil {
-			log.Fatalf("Failed to list DynamoDB tables: %v", err)
+			return DynamoDb{}, fmt.Errorf("failed to list DynamoDB tables: %v", err)
 		}
 
 		tables = append(tables, aws.StringValueSlice(result.TableNames)...)
@@ -29,18 +30,15 @@ func printdynamoTableStats(svc *dynamodb.DynamoDB) error {
 		input.ExclusiveStartTableName = result.LastEvaluatedTableName
 	}
 
-	// Set counters
-	counter := 0
-	provisionedTables := 0
-	ondemandTables := 0
+	stats := DynamoDb{}
 
 	for _, tableName := range tables {
 
 		// Get table description
 		descParams := &dynamodb.DescribeTableInput{TableName: aws.String(tableName)}
-		tableDescription, err := svc.DescribeTable(descParams)
+		tableDescription, err := svc.DescribeTableWithContext(ctx, descParams)
 		if err != nil {
-			return err
+			return stats, err
 		}
 		if tableDescription.Table.ProvisionedThroughput != nil &&
 			tableDescription.Table.ProvisionedThroughput.WriteCapacityUnits != nil {
@@ -48,26 +46,54 @@ func printdynamoTableStats(svc *dynamodb.DynamoDB) error {
 
 			if *tableDescription.Table.ProvisionedThroughput.WriteCapacityUnits != 0 {
 
-				provisionedTables++
+				stats.ProvisionedTables++
 
 			} else {
-				ondemandTables++
+				stats.OnDemandTables++
 			}
 
 		}
 
 		// Increment counter
-		counter++
+		stats.TotalTables++
 	}
 
-	// Calculate percentages
-	totalTables := float64(counter)
-	provisionedPercentage := math.Round(float64(provisionedTables) * 100 / totalTables)
-	ondemandPercentage := math.Round(float64(ondemandTables) * 100 / totalTables)
+	return stats, nil
+}
+
+var dynamoCapacityCheck = &basicCheck{
+	id:          "dynamodb-capacity-modes",
+	service:     "dynamodb",
+	severity:    SeverityInfo,
+	category:    CategoryCost,
+	description: "DynamoDB table capacity modes",
+	help:        "Tables with steady traffic are usually cheaper in provisioned mode with auto scaling, tables with spiky or unpredictable traffic in on-demand mode.",
+}
 
-	fmt.Printf("Total tables: %d\n", counter)
-	fmt.Printf("Provisioned tables: %d (%.1f%%)\n", provisionedTables, provisionedPercentage)
-	fmt.Printf("On-Demand tables: %d (%.1f%%)\n", ondemandTables, ondemandPercentage)
+func init() {
+	dynamoCapacityCheck.run = runDynamoCapacityCheck
+	registerCheck(dynamoCapacityCheck)
+}
 
-	return nil
+func runDynamoCapacityCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	stats, err := getDynamoTableStats(ctx, clients.DynamoDB)
+	if err != nil {
+		return nil, err
+	}
+	report.Update(func(f *Findings) {
+		f.DynamoDb = stats
+	})
+	if stats.TotalTables == 0 {
+		return nil, nil
+	}
+	// Calculate percentages
+	totalTables := float64(stats.TotalTables)
+	provisionedPercentage := math.Round(float64(stats.ProvisionedTables) * 100 / totalTables)
+	ondemandPercentage := math.Round(float64(stats.OnDemandTables) * 100 / totalTables)
+
+	return []Finding{
+		dynamoCapacityCheck.finding("", "Total tables: %d", stats.TotalTables).withAttribute("capacityMode", "all"),
+		dynamoCapacityCheck.finding("", "Provisioned tables: %d (%.1f%%)", stats.ProvisionedTables, provisionedPercentage).withAttribute("capacityMode", "provisioned"),
+		dynamoCapacityCheck.finding("", "On-Demand tables: %d (%.1f%%)", stats.OnDemandTables, ondemandPercentage).withAttribute("capacityMode", "on-demand"),
+	}, nil
 }
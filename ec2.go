@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"math/rand"
 	"net"
 	"os"
 	"sort"
@@ -11,9 +13,11 @@ import (
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/cloudtrail"
 	"github.com/aws/aws-sdk-go/service/cloudwatch"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/mattn/go-isatty"
 	"github.com/mikioh/ipaddr"
 )
 
@@ -23,105 +27,83 @@ type InstanceTypePercentage struct {
 	Percentage   float64
 }
 
-// function to get a list of snapshot ids that are owned by the account
-func getSnapshotIds(region string) []string {
-	// Create a new session with the AWS SDK
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(region),
-	})
-	if err != nil {
-		fmt.Println("Failed to create session:", err)
-		return nil
-	}
-
-	svc := ec2.New(sess)
-
-	// Get list of all snapshot ids that are owned by the account
-	var snpshotIds []string
-	err = svc.DescribeSnapshotsPages(&ec2.DescribeSnapshotsInput{
+// getSnapshots lists the snapshots owned by the account
+func getSnapshots(ctx context.Context, svc *ec2.EC2) ([]*ec2.Snapshot, error) {
+	var snapshots []*ec2.Snapshot
+	err := svc.DescribeSnapshotsPagesWithContext(ctx, &ec2.DescribeSnapshotsInput{
 		OwnerIds:   []*string{aws.String("self")},
 		MaxResults: aws.Int64(100),
 	}, func(page *ec2.DescribeSnapshotsOutput, lastPage bool) bool {
-		for _, snapshot := range page.Snapshots {
-			snpshotIds = append(snpshotIds, *snapshot.SnapshotId)
-		}
+		snapshots = append(snapshots, page.Snapshots...)
 		return !lastPage
-
 	})
 	if err != nil {
-		fmt.Println("Failed to describe snapshots:", err)
-		return nil
+		return nil, fmt.Errorf("failed to describe snapshots: %v", err)
 	}
-	return snpshotIds
+	return snapshots, nil
 }
 
-//a function to check if a snapshot has public permission to create volume returns boolean value
-func checkSnapshot(snapshotId string, region string) bool {
-	// Create a new session with the AWS SDK
-	sess, err := session.NewSession(&aws.Config{ // can enhance this by makin it an input to function
-		Region: aws.String(region),
-	})
-	if err != nil {
-		fmt.Println("Failed to create session:", err)
-		os.Exit(1)
+// function to get a list of snapshot ids that are owned by the account
+func getSnapshotIds(snapshots []*ec2.Snapshot) []string {
+	snpshotIds := make([]string, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		snpshotIds = append(snpshotIds, aws.StringValue(snapshot.SnapshotId))
 	}
-	svc := ec2.New(sess)
+	return snpshotIds
+}
+
+// checkSnapshot tells whether a snapshot has public permission to create volume, and which
+// accounts it is shared with
+func checkSnapshot(ctx context.Context, svc *ec2.EC2, snapshotId string) (bool, []string, error) {
 	// describe snapshot attribute
-	snapshotAttributes, err := svc.DescribeSnapshotAttribute(&ec2.DescribeSnapshotAttributeInput{
+	snapshotAttributes, err := svc.DescribeSnapshotAttributeWithContext(ctx, &ec2.DescribeSnapshotAttributeInput{
 		Attribute:  aws.String("createVolumePermission"),
 		SnapshotId: aws.String(snapshotId),
 	})
 	if err != nil {
-		fmt.Println("Failed to describe snapshot:", err)
-		return false
-	}
-	// loop over snapshot attribute and print them
-	if len(snapshotAttributes.CreateVolumePermissions) > 0 {
-		for _, snapshot := range snapshotAttributes.CreateVolumePermissions {
-			if snapshot.Group != nil && *snapshot.Group == "all" {
-				fmt.Println("\nA snapshot has public permission to create volume. Please investigate snapshot: ❌", snapshotId)
-				return true
-			}
+		return false, nil, err
+	}
+	// loop over snapshot attribute and look for the public group
+	public := false
+	var accounts []string
+	for _, snapshot := range snapshotAttributes.CreateVolumePermissions {
+		if snapshot.Group != nil && *snapshot.Group == "all" {
+			public = true
+		}
+		if snapshot.UserId != nil {
+			accounts = append(accounts, *snapshot.UserId)
 		}
-
 	}
 
-	return false
+	return public, accounts, nil
 }
 
-// Function that takes session as input and describes addresses and checks for assosciationid is empty
-func checkElasticIPs(sess *session.Session) bool {
-	svc := ec2.New(sess)
-	filters := []*ec2.Filter{
-		{
-			Name:   aws.String("association-id"),
-			Values: []*string{aws.String("")},
-		},
-	}
-	addresses, err := svc.DescribeAddresses(&ec2.DescribeAddressesInput{
-		Filters: filters,
-	})
+// Function that takes an EC2 client as input and describes addresses and returns those with an empty assosciationid
+func checkElasticIPs(ctx context.Context, svc *ec2.EC2) ([]*ec2.Address, error) {
+	addresses, err := svc.DescribeAddressesWithContext(ctx, &ec2.DescribeAddressesInput{})
 	if err != nil {
-		fmt.Println("Failed to describe addresses:", err)
-		return false
+		return nil, err
 	}
+	var unassociated []*ec2.Address
 	for _, address := range addresses.Addresses {
 		if address.AssociationId == nil {
-			fmt.Println("An elastic IP is not associated with any instance. Please investigate  and release elastic IP: ❌", *address.PublicIp)
-			return true
+			unassociated = append(unassociated, address)
 		}
 	}
-	return false
+	return unassociated, nil
 }
 
-func fetchSubnets(sess *session.Session) []*ec2.Subnet {
-	ec2Client := ec2.New(sess)
+func fetchSubnets(ctx context.Context, ec2Client *ec2.EC2) ([]*ec2.Subnet, error) {
+	var subnets []*ec2.Subnet
 	input := &ec2.DescribeSubnetsInput{}
-	result, err := ec2Client.DescribeSubnets(input)
+	err := ec2Client.DescribeSubnetsPagesWithContext(ctx, input, func(page *ec2.DescribeSubnetsOutput, lastPage bool) bool {
+		subnets = append(subnets, page.Subnets...)
+		return !lastPage
+	})
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return result.Subnets
+	return subnets, nil
 }
 
 type SubnetInfo struct {
@@ -140,44 +122,51 @@ func extractSubnetInfo(subnets []*ec2.Subnet) []SubnetInfo {
 	}
 	return subnetInfoList
 }
-func checkSubnetOverlaps(arr []SubnetInfo) {
+
+// checkSubnetOverlaps returns every pair of subnets whose CIDR ranges overlap
+func checkSubnetOverlaps(arr []SubnetInfo) ([][2]SubnetInfo, error) {
 	prefixList := make([]*ipaddr.Prefix, len(arr))
 	for i, cidr := range arr {
 		_, ipNet, err := net.ParseCIDR(cidr.Cidr)
 		if err != nil {
-			fmt.Printf("Error parsing CIDR: %s, error: %v\n", cidr, err)
-			return
+			return nil, fmt.Errorf("error parsing CIDR %s: %v", cidr.Cidr, err)
 		}
 		prefixList[i] = ipaddr.NewPrefix(ipNet)
 	}
-	//fmt.Println("subnets are ", prefixList)
-	foundOverlapping := false
+	var overlapping [][2]SubnetInfo
 	for i := 0; i < len(prefixList); i++ {
 		for j := i + 1; j < len(prefixList); j++ {
 			if prefixList[i].Overlaps(prefixList[j]) {
-				fmt.Printf("Subnets %s and %s overlap ⚠️ \n", arr[i].Id, arr[j].Id)
-				foundOverlapping = true
+				overlapping = append(overlapping, [2]SubnetInfo{arr[i], arr[j]})
 			}
 		}
 	}
-	if !foundOverlapping {
-		fmt.Println("\n No overlapping subnets found ✅")
-	}
+	return overlapping, nil
 }
 
-func findOrphanedEBSVolumes(svc *ec2.EC2) ([]*ec2.Volume, error) {
-	input := &ec2.DescribeVolumesInput{
-		MaxResults: aws.Int64(200),
-	}
+const (
+	// cloudTrailRetention is how far back CloudTrail event history goes
+	cloudTrailRetention = 90 * 24 * time.Hour
+	// cloudTrailLookupInterval paces LookupEvents, which allows two calls per second
+	cloudTrailLookupInterval = 500 * time.Millisecond
+	// maxDetachmentLookups is how many orphaned volumes per region are looked up in CloudTrail
+	maxDetachmentLookups = 100
+)
+
+// daysSince returns the number of whole days since t
+func daysSince(t time.Time) int {
+	return int(time.Since(t).Hours() / 24)
+}
 
-	result, err := svc.DescribeVolumes(input)
+func findOrphanedEBSVolumes(ctx context.Context, clients *Clients, report *Report) ([]*ec2.Volume, error) {
+	volumes, err := regionVolumes(ctx, clients, report)
 	if err != nil {
 		return nil, err
 	}
 
 	orphanedVolumes := make([]*ec2.Volume, 0)
 
-	for _, volume := range result.Volumes {
+	for _, volume := range volumes {
 		if aws.StringValue(volume.State) == "available" && len(volume.Attachments) == 0 {
 			orphanedVolumes = append(orphanedVolumes, volume)
 		}
@@ -186,47 +175,89 @@ func findOrphanedEBSVolumes(svc *ec2.EC2) ([]*ec2.Volume, error) {
 	return orphanedVolumes, nil
 }
 
-func performEC2Checks(svc *ec2.EC2, cpuThreshold int, timeFrame time.Duration) {
-	input := &ec2.DescribeInstancesInput{}
+// findVolumeDetachments looks up in CloudTrail when every volume was last detached. CloudTrail
+// keeps events for cloudTrailRetention, so volumes detached before that, or never attached, are
+// left out. Lookups are paced to the rate CloudTrail allows.
+func findVolumeDetachments(ctx context.Context, svc *cloudtrail.CloudTrail, volumeIds []string) (map[string]time.Time, error) {
+	detached := make(map[string]time.Time)
+	ticker := time.NewTicker(cloudTrailLookupInterval)
+	defer ticker.Stop()
+	for _, id := range volumeIds {
+		err := svc.LookupEventsPagesWithContext(ctx, &cloudtrail.LookupEventsInput{
+			LookupAttributes: []*cloudtrail.LookupAttribute{{
+				AttributeKey:   aws.String(cloudtrail.LookupAttributeKeyResourceName),
+				AttributeValue: aws.String(id),
+			}},
+			StartTime: aws.Time(time.Now().Add(-cloudTrailRetention)),
+		}, func(page *cloudtrail.LookupEventsOutput, lastPage bool) bool {
+			// Events are listed newest first
+			for _, event := range page.Events {
+				if aws.StringValue(event.EventName) == "DetachVolume" {
+					detached[id] = aws.TimeValue(event.EventTime)
+					return false
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return detached, err
+		}
+		select {
+		case <-ctx.Done():
+			return detached, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return detached, nil
+}
 
-	onDemandCount := 0
-	spotCount := 0
-	instanceTypeCounts := make(map[string]int)
+// ec2Tags turns EC2 resource tags into a map
+func ec2Tags(tags []*ec2.Tag) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		m[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return m
+}
+
+func instanceIDs(instances []*ec2.Instance) []string {
+	ids := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		ids = append(ids, aws.StringValue(instance.InstanceId))
+	}
+	return ids
+}
+
+// describeInstances returns every instance in the region, whatever its state
+func describeInstances(ctx context.Context, svc *ec2.EC2) ([]*ec2.Instance, error) {
 	instances := make([]*ec2.Instance, 0)
-	runningInstances := make([]*ec2.Instance, 0)
-	// Counting On-Demand and Spot instances
-	err := svc.DescribeInstancesPages(input,
+	err := svc.DescribeInstancesPagesWithContext(ctx, &ec2.DescribeInstancesInput{},
 		func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
 			for _, reservation := range page.Reservations {
-				for _, instance := range reservation.Instances {
-					instances = append(instances, instance)
-					if *instance.State.Name == "running" {
-						runningInstances = append(runningInstances, instance)
-					}
-					if instance.InstanceLifecycle != nil && *instance.InstanceLifecycle == "spot" {
-						spotCount++
-					} else {
-						onDemandCount++
-					}
-					instanceTypeCounts[*instance.InstanceType]++
-
-				}
+				instances = append(instances, reservation.Instances...)
 			}
 			return !lastPage
 		})
-
 	if err != nil {
-		fmt.Println("Error describing instances:", err)
-		return
+		return nil, err
+	}
+	return instances, nil
+}
+
+func runningInstances(instances []*ec2.Instance) []*ec2.Instance {
+	running := make([]*ec2.Instance, 0)
+	for _, instance := range instances {
+		if *instance.State.Name == "running" {
+			running = append(running, instance)
+		}
 	}
-	// check for imdv1 instances
-	checkForIMDv1Instances(instances)
+	return running
+}
 
-	// check for underutilized instances
-	floatCpuThreshold := float64(cpuThreshold)
-	//fmt.Println("cpu threshold is ", floatCpuThreshold)
-	//fmt.Println("timeframe is ", timeFrame)
-	displayUnderutilizedInstances(runningInstances, floatCpuThreshold, timeFrame)
+func describeReservedInstances(ctx context.Context, svc *ec2.EC2) ([]*ec2.ReservedInstances, error) {
 	// Counting Reserved instances
 	reservedInput := &ec2.DescribeReservedInstancesInput{
 		Filters: []*ec2.Filter{
@@ -236,65 +267,67 @@ func performEC2Checks(svc *ec2.EC2, cpuThreshold int, timeFrame time.Duration) {
 			},
 		},
 	}
-	// check EC2 instances for multiple ENIs
-	checkForMultipleENIs(instances, *svc.Config.Region)
-
-	reservedInstances, err := svc.DescribeReservedInstances(reservedInput)
+	reservedInstances, err := svc.DescribeReservedInstancesWithContext(ctx, reservedInput)
 	if err != nil {
-		fmt.Println("Error describing reserved instances:", err)
-		return
-	}
-
-	fmt.Printf("\nFound %d purchases of reserved instances\n", len(reservedInstances.ReservedInstances))
-	for _, reservedInstance := range reservedInstances.ReservedInstances {
-		years := float64(*reservedInstance.Duration) / (60 * 60 * 24 * 365)
-		// Safely dereference pointers by checking for nil
-
-		// Calculate days until expiration
-		// Calculate the number of days until the Reserved Instance expires
-		expiresInSeconds := "N/A"
-		if reservedInstance.End != nil {
-			now := time.Now()
-			if reservedInstance.End.After(now) {
-				duration := reservedInstance.End.Sub(now)
-				daysUntilExpiration := duration.Hours() / 24
-				expiresInSeconds = fmt.Sprintf("%.0f days", daysUntilExpiration)
-			} else {
-				expiresInSeconds = "Expired"
-			}
-		}
-		instanceType := "N/A" // Default value in case of nil
-		if reservedInstance.InstanceType != nil {
-			instanceType = *reservedInstance.InstanceType
-		}
+		return nil, err
+	}
+	return reservedInstances.ReservedInstances, nil
+}
 
-		availabilityZone := "N/A" // Default value in case of nil
-		if reservedInstance.AvailabilityZone != nil {
-			availabilityZone = *reservedInstance.AvailabilityZone
+// describeReservedInstance summarises a reservation in one line
+func describeReservedInstance(reservedInstance *ec2.ReservedInstances) string {
+	years := float64(*reservedInstance.Duration) / (60 * 60 * 24 * 365)
+	// Safely dereference pointers by checking for nil
+
+	// Calculate the number of days until the Reserved Instance expires
+	expiresInSeconds := "N/A"
+	if reservedInstance.End != nil {
+		now := time.Now()
+		if reservedInstance.End.After(now) {
+			duration := reservedInstance.End.Sub(now)
+			daysUntilExpiration := duration.Hours() / 24
+			expiresInSeconds = fmt.Sprintf("%.0f days", daysUntilExpiration)
+		} else {
+			expiresInSeconds = "Expired"
 		}
+	}
+	instanceType := "N/A" // Default value in case of nil
+	if reservedInstance.InstanceType != nil {
+		instanceType = *reservedInstance.InstanceType
+	}
+
+	availabilityZone := "N/A" // Default value in case of nil
+	if reservedInstance.AvailabilityZone != nil {
+		availabilityZone = *reservedInstance.AvailabilityZone
+	}
+
+	instanceCount := -1 // Default value in case of nil
+	if reservedInstance.InstanceCount != nil {
+		instanceCount = int(*reservedInstance.InstanceCount)
+	}
+	return fmt.Sprintf("Instance Type: %s, Availability Zone: %s, Instance Count: %d, Duration: %.2f years , Expires in: %s",
+		instanceType,
+		availabilityZone,
+		instanceCount,
+		years,
+		expiresInSeconds,
+	)
+}
 
-		instanceCount := -1 // Default value in case of nil
-		if reservedInstance.InstanceCount != nil {
-			instanceCount = int(*reservedInstance.InstanceCount)
+// instanceTypePercentages counts on-demand and spot instances and the share of each instance type, largest first
+func instanceTypePercentages(instances []*ec2.Instance) (onDemandCount int, spotCount int, instancePercentages []InstanceTypePercentage) {
+	instanceTypeCounts := make(map[string]int)
+	for _, instance := range instances {
+		if instance.InstanceLifecycle != nil && *instance.InstanceLifecycle == "spot" {
+			spotCount++
+		} else {
+			onDemandCount++
 		}
-		fmt.Printf("Instance Type: %s, Availability Zone: %s, Instance Count: %d, Duration: %.2f years , Expires in: %s\n",
-			instanceType,
-			availabilityZone,
-			instanceCount,
-			years,
-			expiresInSeconds,
-		)
-	}
-	// Calculate On-Demand and Spot instances percentages
-	onDemandPercentage := float64(onDemandCount) / float64(onDemandCount+spotCount) * 100
-	spotPercentage := float64(spotCount) / float64(onDemandCount+spotCount) * 100
-
-	// Print On-Demand and Spot instances percentages
-	fmt.Printf("\nOn-Demand Instances: %d (%.2f%%)\n", onDemandCount, onDemandPercentage)
-	fmt.Printf("Spot Instances: %d (%.2f%%)\n", spotCount, spotPercentage)
+		instanceTypeCounts[*instance.InstanceType]++
+	}
 
 	totalInstances := onDemandCount + spotCount
-	instancePercentages := make([]InstanceTypePercentage, 0, len(instanceTypeCounts))
+	instancePercentages = make([]InstanceTypePercentage, 0, len(instanceTypeCounts))
 
 	for instanceType, count := range instanceTypeCounts {
 		percentage := float64(count) / float64(totalInstances) * 100
@@ -307,26 +340,20 @@ func performEC2Checks(svc *ec2.EC2, cpuThreshold int, timeFrame time.Duration) {
 	sort.Slice(instancePercentages, func(i, j int) bool {
 		return instancePercentages[i].Percentage > instancePercentages[j].Percentage
 	})
-	fmt.Println("\n Instance type distribution")
-	fmt.Println("=========================================")
+	return onDemandCount, spotCount, instancePercentages
+}
+
+// instanceTypeBar draws a percentage as a bar of at most 15 characters
+func instanceTypeBar(percentage float64) string {
 	// Define the maximum bar width
 	const maxBarWidth = 15
-
-	for _, instancePercentage := range instancePercentages {
-		// Calculate the bar width based on the percentage
-		barWidth := int(math.Round(float64(maxBarWidth) * instancePercentage.Percentage / 100))
-
-		// Create the bar using the Unicode character █
-		bar := strings.Repeat("█", barWidth)
-
-		// Print the result
-		fmt.Printf("Instace Type: %-20s: %s (%7.1f%%)\n\n", instancePercentage.InstanceType, bar, instancePercentage.Percentage)
-	}
-	fmt.Println("=========================================")
+	// Calculate the bar width based on the percentage
+	barWidth := int(math.Round(float64(maxBarWidth) * percentage / 100))
+	// Create the bar using the Unicode character █
+	return strings.Repeat("█", barWidth)
 }
 
-func checkForIMDv1Instances(instances []*ec2.Instance) {
-	fmt.Printf("\nChecking %d instances for (IMDv1)\n", len(instances))
+func checkForIMDv1Instances(instances []*ec2.Instance) []*ec2.Instance {
 	imdv1Instances := make([]*ec2.Instance, 0)
 	for _, instance := range instances {
 		imdv1Instance := false
@@ -340,26 +367,10 @@ func checkForIMDv1Instances(instances []*ec2.Instance) {
 			imdv1Instances = append(imdv1Instances, instance)
 		}
 	}
-
-	if len(imdv1Instances) > 0 {
-		fmt.Printf("Found %d instances using instance metadata version 1 (IMDv1):\n", len(imdv1Instances))
-		for _, instance := range imdv1Instances {
-			fmt.Printf("- Instance ID: %s\n", *instance.InstanceId)
-		}
-	} else {
-		fmt.Println("No instances found using instance metadata version 1 (IMDv1).")
-	}
+	return imdv1Instances
 }
-func checkForMultipleENIs(instances []*ec2.Instance, region string) {
-	fmt.Printf("\nChecking %d instances for multiple ENIs\n", len(instances))
+func checkForMultipleENIs(ctx context.Context, svc *ec2.EC2, instances []*ec2.Instance) ([]*ec2.Instance, error) {
 	multipleENIsInstances := make([]*ec2.Instance, 0)
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(region),
-	})
-	if err != nil {
-		fmt.Println("Failed to create session:", err)
-	}
-	svc := ec2.New(sess)
 
 	for _, instance := range instances {
 		input := &ec2.DescribeNetworkInterfacesInput{
@@ -370,31 +381,18 @@ func checkForMultipleENIs(instances []*ec2.Instance, region string) {
 				},
 			},
 		}
-		result, err := svc.DescribeNetworkInterfaces(input)
+		result, err := svc.DescribeNetworkInterfacesWithContext(ctx, input)
 		if err != nil {
-			fmt.Println("Error describing network interfaces:", err)
-			continue
+			return nil, err
 		}
 		if len(result.NetworkInterfaces) > 1 {
 			multipleENIsInstances = append(multipleENIsInstances, instance)
 		}
 	}
-
-	if len(multipleENIsInstances) > 0 {
-		fmt.Printf("Found %d instances with multiple ENIs:\n", len(multipleENIsInstances))
-		for _, instance := range multipleENIsInstances {
-			fmt.Printf("- Instance ID: %s\n", *instance.InstanceId)
-		}
-	} else {
-		fmt.Println("No instances found with multiple ENIs.")
-	}
+	return multipleENIsInstances, nil
 }
 
-func getInstanceAverageCPU(instance *ec2.Instance, timeframe time.Duration) (float64, error) {
-	cwSess := session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable,
-	}))
-	svc := cloudwatch.New(cwSess)
+func getInstanceAverageCPU(ctx context.Context, svc *cloudwatch.CloudWatch, instance *ec2.Instance, timeframe time.Duration) (float64, error) {
 	endTime := time.Now()
 	startTime := endTime.Add(-timeframe)
 
@@ -420,7 +418,7 @@ func getInstanceAverageCPU(instance *ec2.Instance, timeframe time.Duration) (flo
 		},
 	}
 
-	output, err := svc.GetMetricStatistics(input)
+	output, err := svc.GetMetricStatisticsWithContext(ctx, input)
 	if err != nil {
 		return 0, err
 	}
@@ -440,15 +438,23 @@ func getInstanceAverageCPU(instance *ec2.Instance, timeframe time.Duration) (flo
 	return average, nil
 }
 
-func displayUnderutilizedInstances(runningInstances []*ec2.Instance, cpuThreshold float64, timeframe time.Duration) {
-	results := make(chan *ec2.Instance, len(runningInstances))
-	cpuUsages := make(chan float64, len(runningInstances))
+// underutilizedInstance is a running instance whose average CPU stayed below the threshold
+type underutilizedInstance struct {
+	Instance   *ec2.Instance
+	AverageCPU float64
+}
+
+// findUnderutilizedInstances returns running instances whose average CPU usage over timeframe is
+// below cpuThreshold, and how many were skipped because they started within the timeframe
+func findUnderutilizedInstances(ctx context.Context, svc *cloudwatch.CloudWatch, runningInstances []*ec2.Instance, cpuThreshold float64, timeframe time.Duration) ([]underutilizedInstance, int, error) {
 	concurrencyLimit := 10
 	sem := make(chan bool, concurrencyLimit)
 
 	var syncMutex sync.Mutex
 	var skipCount int
+	var firstErr error
 	var wg sync.WaitGroup
+	results := make([]underutilizedInstance, 0)
 
 	for _, instance := range runningInstances {
 		wg.Add(1)
@@ -458,38 +464,593 @@ func displayUnderutilizedInstances(runningInstances []*ec2.Instance, cpuThreshol
 				<-sem
 				wg.Done()
 			}()
-			averageCPU, err := getInstanceAverageCPU(instance, timeframe)
+			averageCPU, err := getInstanceAverageCPU(ctx, svc, instance, timeframe)
+			syncMutex.Lock()
+			defer syncMutex.Unlock()
 			if err != nil {
 				if strings.Contains(err.Error(), "this instance was started within the specified time period:") {
-					syncMutex.Lock()
 					skipCount++
-					syncMutex.Unlock()
-				} else {
-					syncMutex.Lock()
-					fmt.Printf("Error getting average CPU for instance %s: %s\n", *instance.InstanceId, err)
-					syncMutex.Unlock()
+				} else if firstErr == nil {
+					firstErr = fmt.Errorf("error getting average CPU for instance %s: %v", *instance.InstanceId, err)
 				}
 				return
 			}
 
 			if averageCPU < cpuThreshold {
-				results <- instance
-				cpuUsages <- averageCPU
+				results = append(results, underutilizedInstance{Instance: instance, AverageCPU: averageCPU})
 			}
 		}(instance)
 	}
 	wg.Wait() // Wait for all goroutines to finish
-	fmt.Printf("Skipped *** %d *** instances because they were started within the specified time period\n", skipCount)
 
-	for i := 0; i < cap(sem); i++ {
-		sem <- true
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].AverageCPU < results[j].AverageCPU
+	})
+	return results, skipCount, firstErr
+}
+
+var (
+	publicSnapshotsCheck = &basicCheck{
+		id:          "ebs-public-snapshots",
+		service:     "ebs",
+		severity:    SeverityHigh,
+		category:    CategorySecurity,
+		description: "EBS snapshots publicly shared",
+		help:        "Anyone can create a volume from a public snapshot. Remove the 'all' group from the createVolumePermission attribute of the snapshot unless it is meant to be public.",
+		arn:         "arn:{partition}:ec2:{region}::snapshot/{id}",
+	}
+	orphanedVolumesCheck = &basicCheck{
+		id:          "ebs-orphaned-volumes",
+		service:     "ebs",
+		severity:    SeverityMedium,
+		category:    CategoryCost,
+		description: "Orphaned EBS volumes",
+		help:        "Volumes that are not attached to any instance are still billed. Snapshot the volume if its data is needed and delete it.",
+		arn:         "arn:{partition}:ec2:{region}:{account}:volume/{id}",
+	}
+	unassociatedEIPsCheck = &basicCheck{
+		id:          "vpc-unassociated-eips",
+		service:     "vpc",
+		severity:    SeverityLow,
+		category:    CategoryCost,
+		description: "Elastic IPs not associated with any instance",
+		help:        "Elastic IPs that are not associated with a running instance are billed by the hour. Release addresses that are no longer needed.",
+		arn:         "arn:{partition}:ec2:{region}:{account}:elastic-ip/{id}",
+	}
+	overlappingSubnetsCheck = &basicCheck{
+		id:          "vpc-overlapping-subnets",
+		service:     "vpc",
+		severity:    SeverityLow,
+		category:    CategoryReliability,
+		description: "Overlapping subnets",
+		help:        "Overlapping CIDR ranges prevent peering and routing between the networks. Re-plan the address ranges so that they do not overlap.",
+		arn:         "arn:{partition}:ec2:{region}:{account}:subnet/{id}",
+	}
+	imdv1Check = &basicCheck{
+		id:          "ec2-imdv1",
+		service:     "ec2",
+		severity:    SeverityMedium,
+		category:    CategorySecurity,
+		description: "Instances using instance metadata version 1 (IMDv1)",
+		help:        "IMDv1 is open to server-side request forgery. Require session tokens with 'aws ec2 modify-instance-metadata-options --http-tokens required' once the software on the instance supports IMDv2.",
+		arn:         "arn:{partition}:ec2:{region}:{account}:instance/{id}",
+	}
+	multipleENIsCheck = &basicCheck{
+		id:          "ec2-multiple-enis",
+		service:     "ec2",
+		severity:    SeverityInfo,
+		category:    CategoryHygiene,
+		description: "Instances with multiple ENIs",
+		help:        "Check that the additional network interfaces are still needed and that they are in the intended subnets and security groups.",
+		arn:         "arn:{partition}:ec2:{region}:{account}:instance/{id}",
+	}
+	underutilizedCheck = &basicCheck{
+		id:          "ec2-underutilized",
+		service:     "ec2",
+		severity:    SeverityLow,
+		category:    CategoryCost,
+		description: "Underutilized instances",
+		help:        "Instances whose average CPU stays low can usually be moved to a smaller or burstable instance type, or stopped when not in use.",
+		arn:         "arn:{partition}:ec2:{region}:{account}:instance/{id}",
+	}
+	reservedInstancesCheck = &basicCheck{
+		id:          "ec2-reserved-instances",
+		service:     "ec2",
+		severity:    SeverityInfo,
+		category:    CategoryCost,
+		description: "Active reserved instance purchases",
+		help:        "Compare the reservations with the running instances to make sure every reservation is used before it is renewed.",
+		arn:         "arn:{partition}:ec2:{region}:{account}:reserved-instances/{id}",
+	}
+	instanceTypesCheck = &basicCheck{
+		id:          "ec2-instance-types",
+		service:     "ec2",
+		severity:    SeverityInfo,
+		category:    CategoryCost,
+		description: "Instance type distribution",
+		help:        "A high share of on-demand instances may be cheaper with reserved instances, savings plans or spot instances.",
+	}
+)
+
+func init() {
+	publicSnapshotsCheck.run = runPublicSnapshotsCheck
+	orphanedVolumesCheck.run = runOrphanedVolumesCheck
+	unassociatedEIPsCheck.run = runUnassociatedEIPsCheck
+	overlappingSubnetsCheck.run = runOverlappingSubnetsCheck
+	imdv1Check.run = runIMDv1Check
+	multipleENIsCheck.run = runMultipleENIsCheck
+	underutilizedCheck.run = runUnderutilizedCheck
+	reservedInstancesCheck.run = runReservedInstancesCheck
+	instanceTypesCheck.run = runInstanceTypesCheck
+
+	registerCheck(publicSnapshotsCheck)
+	registerCheck(orphanedVolumesCheck)
+	registerCheck(unassociatedEIPsCheck)
+	registerCheck(overlappingSubnetsCheck)
+	registerCheck(imdv1Check)
+	registerCheck(multipleENIsCheck)
+	registerCheck(underutilizedCheck)
+	registerCheck(reservedInstancesCheck)
+	registerCheck(instanceTypesCheck)
+}
+
+func runPublicSnapshotsCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	sharing, err := regionSnapshotSharing(ctx, clients, report)
+	if sharing == nil {
+		return nil, err
+	}
+	report.RecordEvaluated(publicSnapshotsCheck.id, sharing.checkedIds)
+	var findings []Finding
+	for _, snapshotId := range sharing.public {
+		findings = append(findings, publicSnapshotsCheck.finding(snapshotId,
+			"A snapshot has public permission to create volume. Please investigate snapshot: %s", snapshotId))
+	}
+
+	report.Update(func(f *Findings) {
+		f.Snapshots.TotalSnapshots = sharing.total
+		f.Snapshots.TotalAnalyzed = len(sharing.checkedIds)
+		f.Snapshots.Sampled = sharing.sampled
+		f.Snapshots.PubliclyShared = len(sharing.public) > 0
+		f.Snapshots.PublicSnapshotIds = sharing.public
+		f.Snapshots.SnapshotsPerSecond = sharing.rate()
+	})
+	if note := sharing.coverage(); note != "" {
+		report.Note(publicSnapshotsCheck.id, "%s", note)
+	}
+	return findings, err
+}
+
+// snapshotSharing is who the snapshots of a region are shared with
+type snapshotSharing struct {
+	total      int
+	sampled    bool
+	checkedIds []string
+	elapsed    time.Duration
+	// public lists the public snapshots, sorted
+	public []string
+	// accounts holds the accounts every snapshot checked is shared with, by snapshot ID
+	accounts map[string][]string
+}
+
+// rate is how many snapshots were checked per second
+func (s *snapshotSharing) rate() float64 {
+	if len(s.checkedIds) == 0 || s.elapsed <= 0 {
+		return 0
+	}
+	return float64(len(s.checkedIds)) / s.elapsed.Seconds()
+}
+
+// coverage tells how many snapshots were checked and how fast, or nothing when there were none
+func (s *snapshotSharing) coverage() string {
+	checked := len(s.checkedIds)
+	if checked == 0 {
+		return ""
+	}
+	coverage := fmt.Sprintf("all %d snapshots", s.total)
+	if s.sampled {
+		coverage = fmt.Sprintf("%d random snapshots of %d", checked, s.total)
+	} else if checked < s.total {
+		coverage = fmt.Sprintf("%d of %d snapshots", checked, s.total)
+	}
+	return fmt.Sprintf("Checked the sharing of %s in %s (%.1f/s)", coverage, s.elapsed.Round(100*time.Millisecond), s.rate())
+}
+
+// regionSnapshots returns the snapshots owned by the account in the region of report, listed once
+// for every check that needs them
+func regionSnapshots(ctx context.Context, clients *Clients, report *Report) ([]*ec2.Snapshot, error) {
+	value, err := report.shared("snapshots", func() (interface{}, error) {
+		return getSnapshots(ctx, clients.EC2)
+	})
+	snapshots, _ := value.([]*ec2.Snapshot)
+	return snapshots, err
+}
+
+// regionSnapshotSharing checks who the snapshots of the region are shared with, once for every
+// check that needs it. Only --sample random snapshots are checked when it is set. On error the
+// sharing of the snapshots checked so far is returned with it, or nil when there is none.
+func regionSnapshotSharing(ctx context.Context, clients *Clients, report *Report) (*snapshotSharing, error) {
+	value, err := report.shared("snapshotSharing", func() (interface{}, error) {
+		snapshots, err := regionSnapshots(ctx, clients, report)
+		if err != nil {
+			return nil, err
+		}
+		snapshotIds := getSnapshotIds(snapshots)
+		sharing := &snapshotSharing{total: len(snapshotIds)}
+		sharing.sampled = clients.SnapshotSample > 0 && len(snapshotIds) > clients.SnapshotSample
+		if sharing.sampled {
+			random := rand.New(rand.NewSource(time.Now().UnixNano()))
+			random.Shuffle(len(snapshotIds), func(i, j int) {
+				snapshotIds[i], snapshotIds[j] = snapshotIds[j], snapshotIds[i]
+			})
+			snapshotIds = snapshotIds[:clients.SnapshotSample]
+		}
+		start := time.Now()
+		sharing.accounts, sharing.public, err = checkSnapshots(ctx, clients.EC2, snapshotIds, clients.SnapshotRate, report.region())
+		sharing.elapsed = time.Since(start)
+		for _, id := range snapshotIds {
+			if _, ok := sharing.accounts[id]; ok {
+				sharing.checkedIds = append(sharing.checkedIds, id)
+			}
+		}
+		sort.Strings(sharing.public)
+		return sharing, err
+	})
+	sharing, _ := value.(*snapshotSharing)
+	return sharing, err
+}
+
+// snapshotWorkers is how many DescribeSnapshotAttribute calls are made at the same time
+const snapshotWorkers = 8
+
+// snapshotProgressInterval is how often the progress of a long snapshot check is printed
+const snapshotProgressInterval = 15 * time.Second
+
+// checkSnapshots checks the sharing of every snapshot with a pool of workers, making at most rate
+// calls per second. It returns the accounts every snapshot checked is shared with, by snapshot ID,
+// and the public snapshots; on the first error the remaining snapshots are left unchecked.
+// Snapshots deleted in the meantime are skipped. When stderr is a terminal, progress is printed to
+// it as the check goes.
+func checkSnapshots(ctx context.Context, svc *ec2.EC2, snapshotIds []string, rate float64, region string) (map[string][]string, []string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// NewTicker panics on an interval under 1ns
+	interval := time.Duration(float64(time.Second) / rate)
+	if interval < time.Nanosecond {
+		interval = time.Nanosecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var (
+		mu       sync.Mutex
+		public   []string
+		accounts = make(map[string][]string)
+		firstErr error
+	)
+	ids := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < snapshotWorkers && i < len(snapshotIds); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				select {
+				case <-ticker.C:
+				case <-ctx.Done():
+					continue
+				}
+				isPublic, sharedWith, err := checkSnapshot(ctx, svc, id)
+				aerr, isAWSError := err.(awserr.Error)
+				mu.Lock()
+				switch {
+				case isAWSError && aerr.Code() == "InvalidSnapshot.NotFound":
+				case err != nil:
+					if firstErr == nil && ctx.Err() == nil {
+						firstErr = fmt.Errorf("failed to check snapshot %s: %v", id, err)
+						cancel()
+					}
+				default:
+					accounts[id] = sharedWith
+					if isPublic {
+						public = append(public, id)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	stopProgress := make(chan struct{})
+	if len(snapshotIds) > 0 && isatty.IsTerminal(os.Stderr.Fd()) {
+		go func() {
+			progress := time.NewTicker(snapshotProgressInterval)
+			defer progress.Stop()
+			start := time.Now()
+			for {
+				select {
+				case <-stopProgress:
+					return
+				case <-progress.C:
+					mu.Lock()
+					done := len(accounts)
+					mu.Unlock()
+					fmt.Fprintf(os.Stderr, "⏳ %s: %d of %d snapshots checked (%.1f/s)\n", region, done, len(snapshotIds), float64(done)/time.Since(start).Seconds())
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, id := range snapshotIds {
+		select {
+		case ids <- id:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(ids)
+	wg.Wait()
+	close(stopProgress)
+
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return accounts, public, firstErr
+}
+
+func runOrphanedVolumesCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	orphanedVolumes, err := findOrphanedEBSVolumes(ctx, clients, report)
+	if err != nil {
+		return nil, err
+	}
+	prices := regionEBSPrices(clients, report, orphanedVolumesCheck.id)
+	costs := make(map[string]volumeCost, len(orphanedVolumes))
+	for _, volume := range orphanedVolumes {
+		cost, ok := prices.volumeCost(volume)
+		if !ok {
+			report.Note(orphanedVolumesCheck.id, "No price for volume type %s, the cost of %s is left out", aws.StringValue(volume.VolumeType), aws.StringValue(volume.VolumeId))
+		}
+		costs[aws.StringValue(volume.VolumeId)] = cost
+	}
+	// Sort the orphaned volumes by their cost
+	sort.Slice(orphanedVolumes, func(i, j int) bool {
+		return costs[aws.StringValue(orphanedVolumes[i].VolumeId)].total() > costs[aws.StringValue(orphanedVolumes[j].VolumeId)].total()
+	})
+
+	// Only the costliest volumes are looked up in CloudTrail, as it allows few lookups per second
+	lookup := make([]string, 0, maxDetachmentLookups)
+	for _, volume := range orphanedVolumes {
+		if len(lookup) == maxDetachmentLookups {
+			report.Note(orphanedVolumesCheck.id, "Looked up when the %d costliest of %d volumes were detached, the age of the others is since they were created", maxDetachmentLookups, len(orphanedVolumes))
+			break
+		}
+		lookup = append(lookup, aws.StringValue(volume.VolumeId))
+	}
+	detached, err := findVolumeDetachments(ctx, clients.CloudTrail, lookup)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		report.Note(orphanedVolumesCheck.id, "Could not look up in CloudTrail when volumes were detached, the age of volumes is since they were created: %v", err)
+		lookup = nil
+	}
+	lookedUp := make(map[string]bool, len(lookup))
+	for _, id := range lookup {
+		lookedUp[id] = true
+	}
+
+	var findings []Finding
+	var orphaned OrphanedEBSVolumes
+	for _, volume := range orphanedVolumes {
+		volumeID := aws.StringValue(volume.VolumeId)
+		volumeSize := aws.Int64Value(volume.Size)
+		cost := costs[volumeID]
+		approxMonthlyCost := cost.total()
+		created := aws.TimeValue(volume.CreateTime)
+		item := OrphanedVolume{
+			VolumeId:       volumeID,
+			VolumeType:     aws.StringValue(volume.VolumeType),
+			SizeGB:         volumeSize,
+			Iops:           aws.Int64Value(volume.Iops),
+			ThroughputMBps: aws.Int64Value(volume.Throughput),
+			MonthlyCost:    approxMonthlyCost,
+			IOPSCost:       cost.IOPS,
+			ThroughputCost: cost.Throughput,
+			CreatedAt:      created,
+		}
+		age := fmt.Sprintf("created %d days ago", daysSince(created))
+		if when, ok := detached[volumeID]; ok {
+			item.DetachedAt = &when
+			item.DaysUnattached = daysSince(when)
+			age = fmt.Sprintf("detached %d days ago", item.DaysUnattached)
+		} else {
+			// The volume has been unattached at least since it was created or since the oldest
+			// event CloudTrail keeps
+			since := created
+			if lookedUp[volumeID] {
+				if oldest := time.Now().Add(-cloudTrailRetention); since.Before(oldest) {
+					since = oldest
+				}
+				age += fmt.Sprintf(", not detached in the last %d days", int(cloudTrailRetention.Hours()/24))
+			}
+			item.DaysUnattached = daysSince(since)
+		}
+		orphaned.Volumes = append(orphaned.Volumes, item)
+		orphaned.TotalSizeGB += volumeSize
+		orphaned.TotalMonthlyCost += approxMonthlyCost
+		findings = append(findings, orphanedVolumesCheck.finding(volumeID,
+			"Volume ID: %s, Type: %s, Size: %d GB, %s, Approximate monthly cost in USD: $%.2f", volumeID, item.VolumeType, volumeSize, age, approxMonthlyCost).
+			withTags(ec2Tags(volume.Tags)).withMonthlyCost(approxMonthlyCost))
 	}
+	report.Update(func(f *Findings) {
+		f.OrphanedEBSVolumes = orphaned
+	})
+	return findings, nil
+}
+
+func runUnassociatedEIPsCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	addresses, err := checkElasticIPs(ctx, clients.EC2)
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	var elasticIPs []ElasticIP
+	for _, address := range addresses {
+		elasticIPs = append(elasticIPs, ElasticIP{
+			AllocationId: aws.StringValue(address.AllocationId),
+			PublicIp:     aws.StringValue(address.PublicIp),
+		})
+		findings = append(findings, unassociatedEIPsCheck.finding(aws.StringValue(address.AllocationId),
+			"An elastic IP is not associated with any instance. Please investigate and release elastic IP: %s", aws.StringValue(address.PublicIp)).withTags(ec2Tags(address.Tags)))
+	}
+	report.Update(func(f *Findings) {
+		f.UnassociatedElasticIPs = elasticIPs
+	})
+	return findings, nil
+}
+
+func runOverlappingSubnetsCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	// Fetch list of subnets
+	subnets, err := fetchSubnets(ctx, clients.EC2)
+	if err != nil {
+		return nil, err
+	}
+	// Extract subnet CIDRs and IDs
+	overlapping, err := checkSubnetOverlaps(extractSubnetInfo(subnets))
+	if err != nil {
+		return nil, err
+	}
+	subnetIDs := make([]string, 0, len(subnets))
+	for _, subnet := range subnets {
+		subnetIDs = append(subnetIDs, aws.StringValue(subnet.SubnetId))
+	}
+	report.RecordEvaluated(overlappingSubnetsCheck.id, subnetIDs)
+	var findings []Finding
+	var overlaps []SubnetOverlap
+	for _, pair := range overlapping {
+		overlaps = append(overlaps, SubnetOverlap{
+			SubnetIds: []string{pair[0].Id, pair[1].Id},
+			Cidrs:     []string{pair[0].Cidr, pair[1].Cidr},
+		})
+		findings = append(findings, overlappingSubnetsCheck.finding(pair[0].Id,
+			"Subnets %s (%s) and %s (%s) overlap", pair[0].Id, pair[0].Cidr, pair[1].Id, pair[1].Cidr).withAttribute("overlapsWith", pair[1].Id))
+	}
+	report.Update(func(f *Findings) {
+		f.OverlappingSubnets = overlaps
+	})
+	return findings, nil
+}
 
-	close(results)
-	close(cpuUsages)
+func runIMDv1Check(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	instances, err := describeInstances(ctx, clients.EC2)
+	if err != nil {
+		return nil, err
+	}
+	report.RecordEvaluated(imdv1Check.id, instanceIDs(instances))
+	var findings []Finding
+	var instanceIds []string
+	for _, instance := range checkForIMDv1Instances(instances) {
+		instanceIds = append(instanceIds, *instance.InstanceId)
+		findings = append(findings, imdv1Check.finding(*instance.InstanceId,
+			"Instance %s allows instance metadata version 1 (IMDv1)", *instance.InstanceId).withTags(ec2Tags(instance.Tags)))
+	}
+	report.Update(func(f *Findings) {
+		f.InstancesAnalysis.TotalInstancesCheckedForIMDv1 = len(instances)
+		f.InstancesAnalysis.InstancesUsingIMDv1 = instanceIds
+	})
+	return findings, nil
+}
 
-	for instance := range results {
-		averageCPU := <-cpuUsages
-		fmt.Printf("Instance %s is underutilized (Average CPU usage: %.2f%%, Threshold: %.2f%%)\n", *instance.InstanceId, averageCPU, cpuThreshold)
+func runMultipleENIsCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	instances, err := describeInstances(ctx, clients.EC2)
+	if err != nil {
+		return nil, err
 	}
+	multipleENIsInstances, err := checkForMultipleENIs(ctx, clients.EC2, instances)
+	if err != nil {
+		return nil, err
+	}
+	report.RecordEvaluated(multipleENIsCheck.id, instanceIDs(instances))
+	var findings []Finding
+	var instanceIds []string
+	for _, instance := range multipleENIsInstances {
+		instanceIds = append(instanceIds, *instance.InstanceId)
+		findings = append(findings, multipleENIsCheck.finding(*instance.InstanceId,
+			"Instance %s has multiple ENIs", *instance.InstanceId).withTags(ec2Tags(instance.Tags)))
+	}
+	report.Update(func(f *Findings) {
+		f.InstancesAnalysis.InstancesWithMultipleENIs = instanceIds
+	})
+	return findings, nil
+}
+
+func runUnderutilizedCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	instances, err := describeInstances(ctx, clients.EC2)
+	if err != nil {
+		return nil, err
+	}
+	cpuThreshold := float64(clients.CPUThreshold)
+	running := runningInstances(instances)
+	underutilized, skipCount, err := findUnderutilizedInstances(ctx, clients.CloudWatch, running, cpuThreshold, clients.Timeframe)
+	if err != nil {
+		return nil, err
+	}
+	report.RecordEvaluated(underutilizedCheck.id, instanceIDs(running))
+	var findings []Finding
+	var underutilizedInstances []UnderutilizedInstance
+	for _, result := range underutilized {
+		underutilizedInstances = append(underutilizedInstances, UnderutilizedInstance{
+			InstanceId: *result.Instance.InstanceId,
+			AverageCPU: result.AverageCPU,
+		})
+		findings = append(findings, underutilizedCheck.finding(*result.Instance.InstanceId,
+			"Instance %s is underutilized (Average CPU usage: %.2f%%, Threshold: %.2f%%)", *result.Instance.InstanceId, result.AverageCPU, cpuThreshold).withTags(ec2Tags(result.Instance.Tags)))
+	}
+	report.Update(func(f *Findings) {
+		f.InstancesAnalysis.UnderutilizedInstances = underutilizedInstances
+		f.InstancesAnalysis.SkippedRecentlyStarted = skipCount
+	})
+	return findings, nil
+}
+
+func runReservedInstancesCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	reservedInstances, err := describeReservedInstances(ctx, clients.EC2)
+	if err != nil {
+		return nil, err
+	}
+	// The reservations are inventory rather than findings, they are only in the report
+	purchases := make([]ReservedInstance, 0, len(reservedInstances))
+	for _, reservedInstance := range reservedInstances {
+		purchases = append(purchases, ReservedInstance{
+			ReservedInstancesId: aws.StringValue(reservedInstance.ReservedInstancesId),
+			Description:         describeReservedInstance(reservedInstance),
+		})
+	}
+	report.Update(func(f *Findings) {
+		f.ReservedInstancePurchases = len(reservedInstances)
+		f.ReservedInstances = purchases
+	})
+	return nil, nil
+}
+
+func runInstanceTypesCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	instances, err := describeInstances(ctx, clients.EC2)
+	if err != nil || len(instances) == 0 {
+		return nil, err
+	}
+	onDemandCount, spotCount, instancePercentages := instanceTypePercentages(instances)
+	distribution := InstanceTypeDistribution{
+		Count:         onDemandCount + spotCount,
+		OnDemandCount: onDemandCount,
+		SpotCount:     spotCount,
+		Types:         make(map[string]string),
+	}
+	for _, instancePercentage := range instancePercentages {
+		distribution.Types[instancePercentage.InstanceType] = fmt.Sprintf("%.1f%%", instancePercentage.Percentage)
+	}
+	// The distribution is inventory rather than findings, it is only in the report
+	report.Update(func(f *Findings) {
+		f.InstanceTypeDistribution = distribution
+	})
+	return nil, nil
 }
@@ -1,21 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ecr"
 )
 
 // function to get an array of repository names
-func getRepositoryNames(sess *session.Session) []string {
-	svc := ecr.New(sess)
-
+func getRepositoryNames(ctx context.Context, svc *ecr.ECR) ([]string, error) {
 	// Get list of all repository names
 	var repositoryNames []string
-	err := svc.DescribeRepositoriesPages(&ecr.DescribeRepositoriesInput{
+	err := svc.DescribeRepositoriesPagesWithContext(ctx, &ecr.DescribeRepositoriesInput{
 		MaxResults: aws.Int64(100),
 	}, func(page *ecr.DescribeRepositoriesOutput, lastPage bool) bool {
 		for _, repository := range page.Repositories {
@@ -24,34 +22,68 @@ func getRepositoryNames(sess *session.Session) []string {
 		return !lastPage
 	})
 	if err != nil {
-		fmt.Println("Failed to describe repositories:", err)
-		return nil
+		return nil, fmt.Errorf("failed to describe repositories: %v", err)
 	}
-	return repositoryNames
+	return repositoryNames, nil
 }
 
-//function that takes an array of repository names and checks if they have public permissions
-func checkRepositoryPermissions(repositoryNames []string, sess *session.Session) bool {
-	svc := ecr.New(sess)
+// function that takes an array of repository names and returns those with public permissions
+func checkRepositoryPermissions(ctx context.Context, repositoryNames []string, svc *ecr.ECR) ([]string, error) {
+	var publicRepositories []string
 	for _, repositoryName := range repositoryNames {
 		policyInput := &ecr.GetRepositoryPolicyInput{
 			RepositoryName: aws.String(repositoryName),
 		}
-		policyOutput, err := svc.GetRepositoryPolicy(policyInput)
+		policyOutput, err := svc.GetRepositoryPolicyWithContext(ctx, policyInput)
 		if err != nil {
 			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "RepositoryPolicyNotFoundException" {
-				//fmt.Printf("\n Repository policy does not exist for %s, skipping\n", repositoryName)
 				continue
-			} else {
-				fmt.Println("Error getting repository policy:", err)
-				return false
 			}
+			return publicRepositories, fmt.Errorf("error getting repository policy: %v", err)
 		}
 		// Check if the policy allows public access
 		if *policyOutput.PolicyText == "{\"Statement\":[{\"Effect\":\"Allow\",\"Principal\":\"*\",\"Action\":[\"ecr:GetDownloadUrlForLayer\",\"ecr:BatchGetImage\",\"ecr:BatchCheckLayerAvailability\"],\"Resource\":\"*\"}]}" {
-			fmt.Println("The repository", repositoryName, "has public access enabled. ❌")
-			return true
+			publicRepositories = append(publicRepositories, repositoryName)
 		}
 	}
-	return false
+	return publicRepositories, nil
+}
+
+var publicRepositoriesCheck = &basicCheck{
+	id:          "ecr-public-repositories",
+	service:     "ecr",
+	severity:    SeverityHigh,
+	category:    CategorySecurity,
+	description: "ECR repositories publicly shared",
+	help:        "Anyone can pull images from a repository whose policy allows the '*' principal. Remove the statement unless the images are meant to be public.",
+	arn:         "arn:{partition}:ecr:{region}:{account}:repository/{id}",
+}
+
+func init() {
+	publicRepositoriesCheck.run = runPublicRepositoriesCheck
+	registerCheck(publicRepositoriesCheck)
+}
+
+func runPublicRepositoriesCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	repositories, err := getRepositoryNames(ctx, clients.ECR)
+	if err != nil {
+		return nil, err
+	}
+	publicRepositories, err := checkRepositoryPermissions(ctx, repositories, clients.ECR)
+	if err != nil {
+		return nil, err
+	}
+	report.RecordEvaluated(publicRepositoriesCheck.id, repositories)
+	var findings []Finding
+	for _, repositoryName := range publicRepositories {
+		findings = append(findings, publicRepositoriesCheck.finding(repositoryName,
+			"The repository %s has public access enabled", repositoryName))
+	}
+	report.Update(func(f *Findings) {
+		f.Repositories = Repositories{
+			TotalAnalyzed:      len(repositories),
+			PublicRepositories: publicRepositories,
+		}
+	})
+	return findings, nil
 }
@@ -1,5 +1,11 @@
 package main
 
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
 type AccountInformation struct {
 	AccountId    string `json:"accountId"`
 	AccountAlias string `json:"accountAlias"`
@@ -8,8 +14,54 @@ type AccountInformation struct {
 }
 
 type Snapshots struct {
-	TotalAnalyzed  int  `json:"totalAnalyzed"`
-	PubliclyShared bool `json:"publiclyShared"`
+	// TotalSnapshots counts the snapshots owned by the account, TotalAnalyzed the ones checked
+	TotalSnapshots    int      `json:"totalSnapshots"`
+	TotalAnalyzed     int      `json:"totalAnalyzed"`
+	Sampled           bool     `json:"sampled,omitempty"`
+	PubliclyShared    bool     `json:"publiclyShared"`
+	PublicSnapshotIds []string `json:"publicSnapshotIds"`
+	// SnapshotsPerSecond is how fast the snapshots were checked
+	SnapshotsPerSecond float64 `json:"snapshotsPerSecond,omitempty"`
+	// SharedWithUntrustedAccounts holds the accounts outside --trusted-accounts every snapshot is
+	// shared with, by snapshot ID
+	SharedWithUntrustedAccounts map[string][]string  `json:"sharedWithUntrustedAccounts,omitempty"`
+	UnencryptedSnapshotIds      []string             `json:"unencryptedSnapshotIds,omitempty"`
+	WithoutVolumeSnapshotIds    []string             `json:"withoutVolumeSnapshotIds,omitempty"`
+	OldSnapshotIds              []string             `json:"oldSnapshotIds,omitempty"`
+	StorageCost                 *SnapshotStorageCost `json:"storageCost,omitempty"`
+}
+
+// SnapshotStorageCost estimates what the snapshots of a region cost, taking the size of their
+// volume as an upper bound of the data they hold
+type SnapshotStorageCost struct {
+	StandardTierGB int64   `json:"standardTierGB"`
+	ArchiveTierGB  int64   `json:"archiveTierGB"`
+	MonthlyCost    float64 `json:"monthlyCostUSD"`
+	// ArchiveCandidatesGB is the size of the old snapshots in the standard tier no AMI uses
+	ArchiveCandidatesGB int64   `json:"archiveCandidatesGB"`
+	ArchiveSavings      float64 `json:"archiveMonthlySavingsUSD"`
+}
+
+type Images struct {
+	// TotalAnalyzed counts the AMIs owned by the account
+	TotalAnalyzed  int      `json:"totalAnalyzed"`
+	PublicImageIds []string `json:"publicImageIds,omitempty"`
+	// SharedWithUntrustedAccounts holds the accounts outside --trusted-accounts every AMI is
+	// shared with, by AMI ID
+	SharedWithUntrustedAccounts map[string][]string `json:"sharedWithUntrustedAccounts,omitempty"`
+	UnusedImageIds              []string            `json:"unusedImageIds,omitempty"`
+	// LeftoverSnapshotIds are the snapshots of AMIs that were deregistered
+	LeftoverSnapshotIds []string    `json:"leftoverSnapshotIds,omitempty"`
+	Costs               []ImageCost `json:"costs,omitempty"`
+}
+
+// ImageCost estimates what the snapshots of an AMI cost, taking the size of their volume as an
+// upper bound of the data they hold
+type ImageCost struct {
+	ImageId     string  `json:"imageId"`
+	Name        string  `json:"name"`
+	SnapshotsGB int64   `json:"snapshotsGB"`
+	MonthlyCost float64 `json:"monthlyCostUSD"`
 }
 
 type OpenPortIssue struct {
@@ -22,19 +74,81 @@ type ExcessivelyOpenInboundRule struct {
 	SecurityGroupId string `json:"securityGroupId"`
 }
 
+type BroadPrivateCidrRule struct {
+	SecurityGroupId string `json:"securityGroupId"`
+	Cidr            string `json:"cidr"`
+}
+
 type SecurityGroups struct {
-	TotalAnalyzed               int                          `json:"totalAnalyzed"`
-	OpenPortIssues              []OpenPortIssue              `json:"openPortIssues"`
-	ExcessivelyOpenInboundRules []ExcessivelyOpenInboundRule `json:"excessivelyOpenInboundRules"`
+	TotalAnalyzed                 int                          `json:"totalAnalyzed"`
+	OpenPortIssues                []OpenPortIssue              `json:"openPortIssues"`
+	ExcessivelyOpenInboundRules   []ExcessivelyOpenInboundRule `json:"excessivelyOpenInboundRules"`
+	BroadPrivateCidrRules         []BroadPrivateCidrRule       `json:"broadPrivateCidrRules"`
+	DefaultSecurityGroupInstances []string                     `json:"defaultSecurityGroupInstances"`
+}
+
+type Repositories struct {
+	TotalAnalyzed      int      `json:"totalAnalyzed"`
+	PublicRepositories []string `json:"publicRepositories"`
+}
+
+type ElasticIP struct {
+	AllocationId string `json:"allocationId"`
+	PublicIp     string `json:"publicIp"`
+}
+
+type SubnetOverlap struct {
+	SubnetIds []string `json:"subnetIds"`
+	Cidrs     []string `json:"cidrs"`
+}
+
+type OrphanedVolume struct {
+	VolumeId       string `json:"volumeId"`
+	VolumeType     string `json:"volumeType"`
+	SizeGB         int64  `json:"sizeGB"`
+	Iops           int64  `json:"iops,omitempty"`
+	ThroughputMBps int64  `json:"throughputMBps,omitempty"`
+	// MonthlyCost includes IOPSCost and ThroughputCost, the charges for provisioned performance
+	MonthlyCost    float64   `json:"monthlyCostUSD"`
+	IOPSCost       float64   `json:"iopsMonthlyCostUSD,omitempty"`
+	ThroughputCost float64   `json:"throughputMonthlyCostUSD,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	// DetachedAt is when CloudTrail last saw the volume detached, if it still has the event
+	DetachedAt *time.Time `json:"detachedAt,omitempty"`
+	// DaysUnattached counts from DetachedAt, or from creation or the oldest CloudTrail event
+	// when the volume was not detached since
+	DaysUnattached int `json:"daysUnattached"`
+}
+
+type OrphanedEBSVolumes struct {
+	Volumes          []OrphanedVolume `json:"volumes"`
+	TotalSizeGB      int64            `json:"totalSizeGB"`
+	TotalMonthlyCost float64          `json:"totalMonthlyCostUSD"`
+}
+
+type OutdatedFunction struct {
+	FunctionName string `json:"functionName"`
+	Runtime      string `json:"runtime"`
 }
 
 type LambdaFunctions struct {
-	TotalAnalyzed    int  `json:"totalAnalyzed"`
-	OutdatedRuntimes bool `json:"outdatedRuntimes"`
+	TotalAnalyzed    int                `json:"totalAnalyzed"`
+	OutdatedRuntimes []OutdatedFunction `json:"outdatedRuntimes"`
+	StorageUsedGB    float64            `json:"storageUsedGB"`
+	StorageQuotaGB   float64            `json:"storageQuotaGB"`
 }
 
 type RDSInstances struct {
-	TotalAnalyzed int `json:"totalAnalyzed"`
+	TotalAnalyzed int        `json:"totalAnalyzed"`
+	Issues        []RDSIssue `json:"issues"`
+}
+
+type EBSOptimization struct {
+	TotalAnalyzed int              `json:"totalAnalyzed"`
+	Issues        []EBSVolumeIssue `json:"issues"`
+	// TotalMonthlySavings adds up the savings of migrating gp2 volumes and lowering unused IOPS
+	TotalMonthlySavings    float64 `json:"totalMonthlySavingsUSD"`
+	SkippedRecentlyCreated int     `json:"skippedRecentlyCreated,omitempty"`
 }
 
 type Bucket struct {
@@ -45,6 +159,7 @@ type Bucket struct {
 type S3Buckets struct {
 	TotalBuckets                            int      `json:"totalBuckets"`
 	Buckets                                 []Bucket `json:"buckets"`
+	BucketsWithoutLifecyclePolicy           []string `json:"bucketsWithoutLifecyclePolicy"`
 	BucketsWithoutLifecyclePolicyPercentage string   `json:"bucketsWithoutLifecyclePolicyPercentage"`
 }
 
@@ -55,35 +170,405 @@ type DynamoDb struct {
 }
 
 type InstanceTypeDistribution struct {
-	Count      int               `json:"count"`
-	Percentage string            `json:"percentage"`
-	Types      map[string]string `json:"types"`
+	Count         int               `json:"count"`
+	OnDemandCount int               `json:"onDemandCount"`
+	SpotCount     int               `json:"spotCount"`
+	Types         map[string]string `json:"types"`
+}
+
+type ReservedInstance struct {
+	ReservedInstancesId string `json:"reservedInstancesId"`
+	Description         string `json:"description"`
+}
+
+type UnderutilizedInstance struct {
+	InstanceId string  `json:"instanceId"`
+	AverageCPU float64 `json:"averageCpu"`
 }
 
 type InstancesAnalysis struct {
-	TotalInstancesCheckedForIMDv1 int      `json:"totalInstancesCheckedForIMDv1"`
-	InstancesUsingIMDv1           []string `json:"instancesUsingIMDv1"`
+	TotalInstancesCheckedForIMDv1 int                     `json:"totalInstancesCheckedForIMDv1"`
+	InstancesUsingIMDv1           []string                `json:"instancesUsingIMDv1"`
+	InstancesWithMultipleENIs     []string                `json:"instancesWithMultipleENIs"`
+	UnderutilizedInstances        []UnderutilizedInstance `json:"underutilizedInstances"`
+	SkippedRecentlyStarted        int                     `json:"skippedRecentlyStarted"`
 }
 
 type Findings struct {
 	Snapshots                 Snapshots                `json:"snapshots"`
+	Images                    Images                   `json:"images"`
 	SecurityGroups            SecurityGroups           `json:"securityGroups"`
-	DefaultSecurityGroupUsage bool                     `json:"defaultSecurityGroupUsage"`
-	BroadPrivateCidrRange     bool                     `json:"broadPrivateCidrRange"`
-	RepositoriesFound         bool                     `json:"repositoriesFound"`
-	OrphanedElasticIPs        bool                     `json:"orphanedElasticIPs"`
-	OverlappingSubnets        bool                     `json:"overlappingSubnets"`
-	OrphanedEBSVolumes        bool                     `json:"orphanedEBSVolumes"`
+	Repositories              Repositories             `json:"repositories"`
+	UnassociatedElasticIPs    []ElasticIP              `json:"unassociatedElasticIPs"`
+	OverlappingSubnets        []SubnetOverlap          `json:"overlappingSubnets"`
+	OrphanedEBSVolumes        OrphanedEBSVolumes       `json:"orphanedEBSVolumes"`
+	EBSOptimization           EBSOptimization          `json:"ebsOptimization"`
 	LambdaFunctions           LambdaFunctions          `json:"lambdaFunctions"`
 	RDSInstances              RDSInstances             `json:"rdsInstances"`
 	InstancesAnalysis         InstancesAnalysis        `json:"instancesAnalysis"`
 	ReservedInstancePurchases int                      `json:"reservedInstancePurchases"`
+	ReservedInstances         []ReservedInstance       `json:"reservedInstances,omitempty"`
 	InstanceTypeDistribution  InstanceTypeDistribution `json:"instanceTypeDistribution"`
 	S3Buckets                 S3Buckets                `json:"s3Buckets"`
 	DynamoDb                  DynamoDb                 `json:"dynamoDb"`
+	TrustedAdvisor            []TrustedAdvisorResult   `json:"trustedAdvisor"`
 }
 
 type MasterStructure struct {
 	AccountInformation AccountInformation `json:"accountInformation"`
-	Findings           Findings           `json:"findings"`
+	// ChecksRun tells consumers which parts of Findings were filled in
+	ChecksRun []string  `json:"checksRun"`
+	Findings  Findings  `json:"findings"`
+	Results   []Finding `json:"results"`
+	// Suppressed findings are left out of Results; findings whose suppression expired are in
+	// both Results and ExpiredSuppressions
+	Suppressed          []SuppressedFinding `json:"suppressed,omitempty"`
+	ExpiredSuppressions []SuppressedFinding `json:"expiredSuppressions,omitempty"`
+	// Baselined findings are in the --baseline file and left out of Results
+	Baselined []Finding `json:"baselined,omitempty"`
+	// Errors holds the error of every check that failed to run, by check ID
+	Errors map[string]string `json:"errors,omitempty"`
+	// Durations holds how many seconds every check took, by check ID
+	Durations map[string]float64 `json:"durations,omitempty"`
+	// Evaluated holds the IDs of the resources a check looked at, for the checks that record them
+	Evaluated map[string][]string `json:"-"`
+	// Notes holds what checks tell about how they ran, such as how much they covered, by check ID
+	Notes map[string][]string `json:"notes,omitempty"`
+}
+
+// Report collects the structured results of one scan. Checks run concurrently, so they only
+// touch it through Update.
+type Report struct {
+	mu           sync.Mutex
+	data         MasterStructure
+	suppressions []Suppression
+	baseline     *Baseline
+	// sharedValues holds what several checks of the region look up, see Report.shared
+	sharedValues map[string]*sharedValue
+}
+
+type sharedValue struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+func newReport(accountInfo AccountInformation, region string, checks []Check, suppressions []Suppression, baseline *Baseline) *Report {
+	accountInfo.RegionCode = region
+	accountInfo.RegionName = regionFullName(region)
+	if region == globalRegion {
+		accountInfo.RegionName = "Global services"
+	}
+	checksRun := make([]string, len(checks))
+	for i, c := range checks {
+		checksRun[i] = c.ID()
+	}
+	return &Report{
+		data: MasterStructure{
+			AccountInformation: accountInfo,
+			ChecksRun:          checksRun,
+			Errors:             make(map[string]string),
+			Durations:          make(map[string]float64),
+			Evaluated:          make(map[string][]string),
+			Notes:              make(map[string][]string),
+		},
+		suppressions: suppressions,
+		baseline:     baseline,
+		sharedValues: make(map[string]*sharedValue),
+	}
+}
+
+func (r *Report) region() string {
+	return r.data.AccountInformation.RegionCode
+}
+
+func (r *Report) accountID() string {
+	return r.data.AccountInformation.AccountId
+}
+
+// Update lets a check fill in its part of the findings
+func (r *Report) Update(fn func(f *Findings)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(&r.data.Findings)
+}
+
+// RecordEvaluated records the resources a check looked at, whether or not they had findings
+func (r *Report) RecordEvaluated(checkID string, resourceIDs []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.data.Evaluated[checkID] = append(r.data.Evaluated[checkID], resourceIDs...)
+}
+
+// shared returns what load returns, calling it only once per region for every key so that
+// checks needing the same AWS calls make them once. Checks running at the same time wait for the
+// first one to load it.
+func (r *Report) shared(key string, load func() (interface{}, error)) (interface{}, error) {
+	r.mu.Lock()
+	v := r.sharedValues[key]
+	if v == nil {
+		v = &sharedValue{}
+		r.sharedValues[key] = v
+	}
+	r.mu.Unlock()
+	v.once.Do(func() { v.value, v.err = load() })
+	return v.value, v.err
+}
+
+// Note records something about how a check ran, printed after its findings
+func (r *Report) Note(checkID, format string, args ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.data.Notes[checkID] = append(r.data.Notes[checkID], fmt.Sprintf(format, args...))
+}
+
+func (r *Report) notes(checkID string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.data.Notes[checkID]...)
+}
+
+// addResults appends the findings of a check to the report
+func (r *Report) addResults(result checkResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := result.Check.ID()
+	if result.Err != nil {
+		r.data.Errors[id] = result.Err.Error()
+	}
+	r.data.Durations[id] = result.Duration.Seconds()
+	r.data.Results = append(r.data.Results, result.Findings...)
+	r.data.Suppressed = append(r.data.Suppressed, result.Suppressed...)
+	r.data.ExpiredSuppressions = append(r.data.ExpiredSuppressions, result.Expired...)
+	r.data.Baselined = append(r.data.Baselined, result.Baselined...)
+}
+
+// Snapshot returns a copy of the report that is safe to read while checks are still running
+func (r *Report) Snapshot() MasterStructure {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data := r.data
+	data.Results = append([]Finding(nil), r.data.Results...)
+	data.Suppressed = append([]SuppressedFinding(nil), r.data.Suppressed...)
+	data.ExpiredSuppressions = append([]SuppressedFinding(nil), r.data.ExpiredSuppressions...)
+	data.Baselined = append([]Finding(nil), r.data.Baselined...)
+	data.Errors = make(map[string]string, len(r.data.Errors))
+	for id, err := range r.data.Errors {
+		data.Errors[id] = err
+	}
+	data.Durations = make(map[string]float64, len(r.data.Durations))
+	for id, seconds := range r.data.Durations {
+		data.Durations[id] = seconds
+	}
+	data.Evaluated = make(map[string][]string, len(r.data.Evaluated))
+	for id, resources := range r.data.Evaluated {
+		data.Evaluated[id] = append([]string(nil), resources...)
+	}
+	data.Notes = make(map[string][]string, len(r.data.Notes))
+	for id, notes := range r.data.Notes {
+		data.Notes[id] = append([]string(nil), notes...)
+	}
+	return data
+}
+
+// AccountSummary rolls the findings of every region up to the account
+type AccountSummary struct {
+	TotalFindings      int              `json:"totalFindings"`
+	FindingsByRegion   map[string]int   `json:"findingsByRegion"`
+	FindingsByCheck    map[string]int   `json:"findingsByCheck"`
+	FindingsBySeverity map[Severity]int `json:"findingsBySeverity"`
+	// TotalBaselined counts the findings left out as they are in the --baseline file
+	TotalBaselined int `json:"totalBaselined,omitempty"`
+}
+
+// AccountReport is the result of scanning one account, one entry per region scanned. Checks
+// of global services are reported once, under the region "global". Error is set when the
+// account could not be scanned at all, for example because the role could not be assumed.
+type AccountReport struct {
+	AccountId    string            `json:"accountId"`
+	AccountAlias string            `json:"accountAlias"`
+	Error        string            `json:"error,omitempty"`
+	Regions      []MasterStructure `json:"regions"`
+	Summary      AccountSummary    `json:"summary"`
+}
+
+func newAccountReport(accountInfo AccountInformation, regions []MasterStructure) AccountReport {
+	summary := AccountSummary{
+		FindingsByRegion:   make(map[string]int),
+		FindingsByCheck:    make(map[string]int),
+		FindingsBySeverity: make(map[Severity]int),
+	}
+	for _, region := range regions {
+		for _, f := range region.Results {
+			summary.TotalFindings++
+			summary.FindingsByRegion[f.Region]++
+			summary.FindingsByCheck[f.CheckID]++
+			summary.FindingsBySeverity[f.Severity]++
+		}
+		summary.TotalBaselined += len(region.Baselined)
+	}
+	return AccountReport{
+		AccountId:    accountInfo.AccountId,
+		AccountAlias: accountInfo.AccountAlias,
+		Regions:      regions,
+		Summary:      summary,
+	}
+}
+
+// OrganizationSummary rolls the findings of every account scanned up to the organisation
+type OrganizationSummary struct {
+	TotalAccounts      int              `json:"totalAccounts"`
+	FailedAccounts     int              `json:"failedAccounts"`
+	TotalFindings      int              `json:"totalFindings"`
+	TotalSuppressed    int              `json:"totalSuppressed"`
+	FindingsBySeverity map[Severity]int `json:"findingsBySeverity"`
+	// Baselined findings are counted apart, as they are not reported
+	TotalBaselined      int              `json:"totalBaselined,omitempty"`
+	BaselinedBySeverity map[Severity]int `json:"baselinedBySeverity,omitempty"`
+	// FindingsByService counts the findings of every service by severity
+	FindingsByService map[string]map[Severity]int `json:"findingsByService"`
+}
+
+// ScanReport is everything a scan produced: one report per account scanned, usually just the
+// account of the credentials in use
+type ScanReport struct {
+	Accounts []AccountReport     `json:"accounts"`
+	Summary  OrganizationSummary `json:"summary"`
+}
+
+func newScanReport(accounts []AccountReport) ScanReport {
+	summary := OrganizationSummary{
+		TotalAccounts:      len(accounts),
+		FindingsBySeverity: make(map[Severity]int),
+		FindingsByService:  make(map[string]map[Severity]int),
+	}
+	for _, account := range accounts {
+		if account.Error != "" {
+			summary.FailedAccounts++
+		}
+		summary.TotalFindings += account.Summary.TotalFindings
+		for severity, count := range account.Summary.FindingsBySeverity {
+			summary.FindingsBySeverity[severity] += count
+		}
+	}
+	report := ScanReport{Accounts: accounts, Summary: summary}
+	summary.TotalSuppressed = len(report.suppressed())
+	for _, f := range report.baselined() {
+		if summary.BaselinedBySeverity == nil {
+			summary.BaselinedBySeverity = make(map[Severity]int)
+		}
+		summary.BaselinedBySeverity[f.Severity]++
+		summary.TotalBaselined++
+	}
+	for _, f := range report.findings() {
+		if summary.FindingsByService[f.Service] == nil {
+			summary.FindingsByService[f.Service] = make(map[Severity]int)
+		}
+		summary.FindingsByService[f.Service][f.Severity]++
+	}
+	report.Summary = summary
+	return report
+}
+
+// findings returns the findings of every account and region, in the order they were reported
+func (r ScanReport) findings() []Finding {
+	var findings []Finding
+	for _, account := range r.Accounts {
+		for _, region := range account.Regions {
+			findings = append(findings, region.Results...)
+		}
+	}
+	return findings
+}
+
+// scopeKey is one check run in one account and region
+type scopeKey struct {
+	check, account, region string
+}
+
+// completedScope returns the checks of report that ran without error, in every account and region
+func (r ScanReport) completedScope() map[scopeKey]bool {
+	scope := make(map[scopeKey]bool)
+	for _, account := range r.Accounts {
+		for _, region := range account.Regions {
+			for _, id := range region.ChecksRun {
+				if _, failed := region.Errors[id]; !failed {
+					scope[scopeKey{id, account.AccountId, region.AccountInformation.RegionCode}] = true
+				}
+			}
+		}
+	}
+	return scope
+}
+
+// scopedFindings returns the findings of every account and region whose check is in scope
+func (r ScanReport) scopedFindings(scope map[scopeKey]bool) (findings []Finding, left int) {
+	for _, account := range r.Accounts {
+		for _, region := range account.Regions {
+			for _, f := range region.Results {
+				if scope[scopeKey{f.CheckID, account.AccountId, region.AccountInformation.RegionCode}] {
+					findings = append(findings, f)
+				} else {
+					left++
+				}
+			}
+		}
+	}
+	return findings, left
+}
+
+// suppressed returns the findings of every account and region left out by a suppression
+func (r ScanReport) suppressed() []SuppressedFinding {
+	var suppressed []SuppressedFinding
+	for _, account := range r.Accounts {
+		for _, region := range account.Regions {
+			suppressed = append(suppressed, region.Suppressed...)
+		}
+	}
+	return suppressed
+}
+
+// baselined returns the findings of every account and region left out as they are in the baseline
+func (r ScanReport) baselined() []Finding {
+	var baselined []Finding
+	for _, account := range r.Accounts {
+		for _, region := range account.Regions {
+			baselined = append(baselined, region.Baselined...)
+		}
+	}
+	return baselined
+}
+
+// expiredSuppressions returns the findings reported again because their suppression expired
+func (r ScanReport) expiredSuppressions() []SuppressedFinding {
+	var expired []SuppressedFinding
+	for _, account := range r.Accounts {
+		for _, region := range account.Regions {
+			expired = append(expired, region.ExpiredSuppressions...)
+		}
+	}
+	return expired
+}
+
+// failedChecks returns the number of checks that failed to run, counted once in every account
+// and region they failed in
+func (r ScanReport) failedChecks() int {
+	count := 0
+	for _, account := range r.Accounts {
+		for _, region := range account.Regions {
+			count += len(region.Errors)
+		}
+	}
+	return count
+}
+
+// countAtLeast returns the number of findings as severe as threshold or more
+func (r ScanReport) countAtLeast(threshold Severity) int {
+	count := 0
+	for _, f := range r.findings() {
+		if f.Severity.atLeast(threshold) {
+			count++
+		}
+	}
+	return count
 }
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// The history database keeps the report of every scan, so that runs can be compared. Runs are
+// numbered from 1; the summary of a run and its full report are kept in separate buckets so
+// that listing runs does not decode every report.
+
+var (
+	runsBucket    = []byte("runs")
+	reportsBucket = []byte("reports")
+)
+
+// historyRun is the summary of one scan kept in the history database
+type historyRun struct {
+	ID   uint64    `json:"id"`
+	Time time.Time `json:"time"`
+	// Origin is what started the scan: triggerScan, triggerSchedule or triggerAPI. Runs saved
+	// before it was recorded have none.
+	Origin string `json:"origin,omitempty"`
+	// Config identifies the accounts, regions, checks and check options of the scan, see
+	// historyConfig, so that a run is only compared with runs that looked for the same findings
+	Config             string           `json:"config,omitempty"`
+	Accounts           []string         `json:"accounts"`
+	Regions            []string         `json:"regions"`
+	TotalFindings      int              `json:"totalFindings"`
+	FindingsBySeverity map[Severity]int `json:"findingsBySeverity"`
+}
+
+type historyStore struct {
+	db *bolt.DB
+}
+
+// historyConfig returns the Config of the runs of cfg: a hash of everything that decides which
+// findings a scan can report
+func historyConfig(cfg *scanConfig) string {
+	sorted := func(values []string) string {
+		values = append([]string(nil), values...)
+		sort.Strings(values)
+		return strings.Join(values, ",")
+	}
+	checks := make([]string, 0, len(cfg.Checks))
+	for _, c := range cfg.Checks {
+		checks = append(checks, c.ID())
+	}
+	parts := []string{
+		"region=" + cfg.Region,
+		"regions=" + sorted(cfg.Regions),
+		"accounts=" + sorted(cfg.Accounts),
+		"checks=" + sorted(checks),
+		fmt.Sprintf("cpu=%d", cfg.CPUThreshold),
+		fmt.Sprintf("timeframe=%s", cfg.Timeframe),
+		fmt.Sprintf("snapshotSample=%d", cfg.SnapshotSample),
+		fmt.Sprintf("snapshotAge=%d", cfg.SnapshotAge),
+		fmt.Sprintf("amiUnusedDays=%d", cfg.AMIUnusedDays),
+		"trustedAccounts=" + sorted(cfg.TrustedAccounts),
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// defaultHistoryPath is ~/.avm/history.db, or nothing when there is no home directory to keep it in
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".avm", "history.db")
+}
+
+// openHistory opens the history database, creating it if needed. The database can only be open in
+// one process at a time, so a scan running next to another one gives up after a few seconds.
+func openHistory(path string) (*historyStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{runsBucket, reportsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open history %s: %v", path, err)
+	}
+	return &historyStore{db: db}, nil
+}
+
+func (h *historyStore) Close() error {
+	return h.db.Close()
+}
+
+func runKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+// Save stores the report of a scan of config that finished at t, started by origin, and returns
+// the run it was saved as
+func (h *historyStore) Save(report ScanReport, t time.Time, origin, config string) (historyRun, error) {
+	run := historyRun{
+		Time:               t.UTC(),
+		Origin:             origin,
+		Config:             config,
+		TotalFindings:      report.Summary.TotalFindings,
+		FindingsBySeverity: report.Summary.FindingsBySeverity,
+	}
+	regions := make(map[string]bool)
+	for _, account := range report.Accounts {
+		run.Accounts = append(run.Accounts, account.AccountId)
+		for _, region := range account.Regions {
+			regions[region.AccountInformation.RegionCode] = true
+		}
+	}
+	for region := range regions {
+		run.Regions = append(run.Regions, region)
+	}
+	sort.Strings(run.Regions)
+
+	err := h.db.Update(func(tx *bolt.Tx) error {
+		runs := tx.Bucket(runsBucket)
+		id, err := runs.NextSequence()
+		if err != nil {
+			return err
+		}
+		run.ID = id
+		summary, err := json.Marshal(run)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(report)
+		if err != nil {
+			return err
+		}
+		if err := runs.Put(runKey(id), summary); err != nil {
+			return err
+		}
+		return tx.Bucket(reportsBucket).Put(runKey(id), data)
+	})
+	if err != nil {
+		return historyRun{}, fmt.Errorf("failed to save the scan to the history: %v", err)
+	}
+	return run, nil
+}
+
+// Runs returns the last limit runs, most recent first, or every run when limit is 0
+func (h *historyStore) Runs(limit int) ([]historyRun, error) {
+	var runs []historyRun
+	err := h.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(runsBucket).Cursor()
+		for k, v := cursor.Last(); k != nil && (limit == 0 || len(runs) < limit); k, v = cursor.Prev() {
+			var run historyRun
+			if err := json.Unmarshal(v, &run); err != nil {
+				return fmt.Errorf("run %d: %v", binary.BigEndian.Uint64(k), err)
+			}
+			runs = append(runs, run)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the history: %v", err)
+	}
+	return runs, nil
+}
+
+// Report returns the summary and the report of a run
+func (h *historyStore) Report(id uint64) (historyRun, ScanReport, error) {
+	var run historyRun
+	var report ScanReport
+	err := h.db.View(func(tx *bolt.Tx) error {
+		summary := tx.Bucket(runsBucket).Get(runKey(id))
+		data := tx.Bucket(reportsBucket).Get(runKey(id))
+		if summary == nil || data == nil {
+			return fmt.Errorf("there is no run %d in the history, see 'avm history'", id)
+		}
+		if err := json.Unmarshal(summary, &run); err != nil {
+			return fmt.Errorf("run %d: %v", id, err)
+		}
+		if err := json.Unmarshal(data, &report); err != nil {
+			return fmt.Errorf("run %d: %v", id, err)
+		}
+		return nil
+	})
+	return run, report, err
+}
+
+// LastOf returns the most recent run of config started by one of origins, or by anything when
+// there are none, with ok false when there is no such run
+func (h *historyStore) LastOf(config string, origins ...string) (run historyRun, report ScanReport, ok bool, err error) {
+	runs, err := h.Runs(0)
+	if err != nil {
+		return historyRun{}, ScanReport{}, false, err
+	}
+	for _, r := range runs {
+		if r.Config != config || (len(origins) > 0 && !contains(origins, r.Origin)) {
+			continue
+		}
+		run, report, err = h.Report(r.ID)
+		if err != nil {
+			return historyRun{}, ScanReport{}, false, err
+		}
+		return run, report, true, nil
+	}
+	return historyRun{}, ScanReport{}, false, nil
+}
+
+// shortList joins a few values and counts longer lists, to keep the history table readable
+func shortList(values []string, plural string) string {
+	if len(values) > 3 {
+		return fmt.Sprintf("%d %s", len(values), plural)
+	}
+	return strings.Join(values, ",")
+}
+
+func runHistoryCommand(args []string) error {
+	fs := flag.NewFlagSet("avm history", flag.ContinueOnError)
+	historyPath := fs.String("history", defaultHistoryPath(), "history database the runs are read from")
+	limit := fs.Int("limit", 20, "number of runs to list, most recent first; 0 lists every run")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if *limit < 0 {
+		return fmt.Errorf("--limit must not be negative, got %d", *limit)
+	}
+	if *historyPath == "" {
+		return fmt.Errorf("no history database, use --history")
+	}
+
+	history, err := openHistory(*historyPath)
+	if err != nil {
+		return err
+	}
+	defer history.Close()
+	runs, err := history.Runs(*limit)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Println("No runs yet, run 'avm scan' first")
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprint(writer, "RUN\tTIME\tORIGIN\tACCOUNTS\tREGIONS")
+	for _, severity := range severities {
+		fmt.Fprintf(writer, "\t%s", strings.ToUpper(string(severity)))
+	}
+	fmt.Fprintln(writer, "\tTOTAL")
+	for _, run := range runs {
+		origin := run.Origin
+		if origin == "" {
+			origin = "-"
+		}
+		fmt.Fprintf(writer, "%d\t%s\t%s\t%s\t%s", run.ID, run.Time.Local().Format("2006-01-02 15:04"), origin,
+			shortList(run.Accounts, "accounts"), shortList(run.Regions, "regions"))
+		for _, severity := range severities {
+			fmt.Fprintf(writer, "\t%d", run.FindingsBySeverity[severity])
+		}
+		fmt.Fprintf(writer, "\t%d\n", run.TotalFindings)
+	}
+	return writer.Flush()
+}
+
+// parseRunID accepts a run number as listed by 'avm history'
+func parseRunID(value string) (uint64, error) {
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("invalid run %q, expected a run number from 'avm history'", value)
+	}
+	return id, nil
+}
+
+// errNoHistory is returned when there are not enough runs to compare
+var errNoHistory = errors.New("not enough runs in the history to compare, run 'avm scan' first")
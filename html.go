@@ -0,0 +1,366 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// htmlChartBar is one bar of a chart, or one segment of a stacked bar
+type htmlChartBar struct {
+	Label   string
+	Percent float64
+	Color   string
+}
+
+type htmlChart struct {
+	Title string
+	Bars  []htmlChartBar
+}
+
+// htmlStackedChart draws one stacked bar per row, for example the storage classes of every bucket
+type htmlStackedChart struct {
+	Title  string
+	Rows   []htmlStackedRow
+	Legend []htmlChartBar
+}
+
+type htmlStackedRow struct {
+	Label    string
+	Segments []htmlChartBar
+}
+
+type htmlServiceSection struct {
+	Service  string
+	Counts   map[Severity]int
+	Findings []Finding
+}
+
+type htmlPage struct {
+	Generated  string
+	Report     ScanReport
+	Severities []Severity
+	Services   []htmlServiceSection
+	Charts     []htmlChart
+	Stacked    []htmlStackedChart
+	Suppressed []SuppressedFinding
+	Expired    []SuppressedFinding
+}
+
+// chartColors are used in turn for the bars of a chart
+var chartColors = []string{"#4e79a7", "#f28e2b", "#59a14f", "#e15759", "#76b7b2", "#edc948", "#b07aa1", "#ff9da7", "#9c755f", "#bab0ac"}
+
+// parsePercentage reads percentages stored in the report as strings such as "42.5%"
+func parsePercentage(value string) float64 {
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil {
+		return 0
+	}
+	return percent
+}
+
+// regionLabel names a region of an account in chart titles, leaving the account out when only
+// one account was scanned
+func regionLabel(report ScanReport, account AccountReport, region MasterStructure) string {
+	if len(report.Accounts) > 1 {
+		return account.AccountId + " " + region.AccountInformation.RegionCode
+	}
+	return region.AccountInformation.RegionCode
+}
+
+// instanceTypeCharts replaces the bars of █ the text output draws for the instance type distribution
+func instanceTypeCharts(report ScanReport) []htmlChart {
+	var charts []htmlChart
+	for _, account := range report.Accounts {
+		for _, region := range account.Regions {
+			distribution := region.Findings.InstanceTypeDistribution
+			if distribution.Count == 0 {
+				continue
+			}
+			chart := htmlChart{Title: fmt.Sprintf("Instance types in %s (%d instances, %d on-demand, %d spot)",
+				regionLabel(report, account, region), distribution.Count, distribution.OnDemandCount, distribution.SpotCount)}
+			for instanceType, percentage := range distribution.Types {
+				chart.Bars = append(chart.Bars, htmlChartBar{Label: instanceType, Percent: parsePercentage(percentage)})
+			}
+			sort.Slice(chart.Bars, func(i, j int) bool {
+				if chart.Bars[i].Percent != chart.Bars[j].Percent {
+					return chart.Bars[i].Percent > chart.Bars[j].Percent
+				}
+				return chart.Bars[i].Label < chart.Bars[j].Label
+			})
+			for i := range chart.Bars {
+				chart.Bars[i].Color = chartColors[0]
+			}
+			charts = append(charts, chart)
+		}
+	}
+	return charts
+}
+
+// storageClassCharts draws the storage classes of the sampled objects of every bucket
+func storageClassCharts(report ScanReport) []htmlStackedChart {
+	var charts []htmlStackedChart
+	for _, account := range report.Accounts {
+		for _, region := range account.Regions {
+			buckets := region.Findings.S3Buckets.Buckets
+			if len(buckets) == 0 {
+				continue
+			}
+			colors := make(map[string]string)
+			var classes []string
+			for _, bucket := range buckets {
+				for storageClass := range bucket.StorageClassPercentages {
+					if _, ok := colors[storageClass]; !ok {
+						colors[storageClass] = ""
+						classes = append(classes, storageClass)
+					}
+				}
+			}
+			sort.Strings(classes)
+			chart := htmlStackedChart{Title: fmt.Sprintf("S3 storage classes in %s", regionLabel(report, account, region))}
+			for i, storageClass := range classes {
+				colors[storageClass] = chartColors[i%len(chartColors)]
+				chart.Legend = append(chart.Legend, htmlChartBar{Label: storageClass, Color: colors[storageClass]})
+			}
+			for _, bucket := range buckets {
+				row := htmlStackedRow{Label: bucket.Name}
+				for _, storageClass := range classes {
+					if percentage, ok := bucket.StorageClassPercentages[storageClass]; ok {
+						row.Segments = append(row.Segments, htmlChartBar{Label: storageClass, Percent: parsePercentage(percentage), Color: colors[storageClass]})
+					}
+				}
+				chart.Rows = append(chart.Rows, row)
+			}
+			charts = append(charts, chart)
+		}
+	}
+	return charts
+}
+
+func serviceSections(report ScanReport) []htmlServiceSection {
+	byService := make(map[string]*htmlServiceSection)
+	var services []string
+	for _, f := range report.findings() {
+		section, ok := byService[f.Service]
+		if !ok {
+			section = &htmlServiceSection{Service: f.Service, Counts: make(map[Severity]int)}
+			byService[f.Service] = section
+			services = append(services, f.Service)
+		}
+		section.Findings = append(section.Findings, f)
+		section.Counts[f.Severity]++
+	}
+	sort.Strings(services)
+	sections := make([]htmlServiceSection, 0, len(services))
+	for _, service := range services {
+		section := byService[service]
+		sort.SliceStable(section.Findings, func(i, j int) bool {
+			return section.Findings[i].Severity.rank() > section.Findings[j].Severity.rank()
+		})
+		sections = append(sections, *section)
+	}
+	return sections
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"add":   func(a, b float64) float64 { return a + b },
+	"rank":  func(s Severity) int { return s.rank() },
+	"upper": func(s Severity) string { return strings.ToUpper(string(s)) },
+	"percent": func(p float64) string {
+		return strconv.FormatFloat(p, 'f', 1, 64)
+	},
+	"regions": func(account AccountReport) string {
+		codes := make([]string, 0, len(account.Regions))
+		for _, region := range account.Regions {
+			codes = append(codes, region.AccountInformation.RegionCode)
+		}
+		return strings.Join(codes, ", ")
+	},
+	"total": func(counts map[Severity]int) int {
+		total := 0
+		for _, count := range counts {
+			total += count
+		}
+		return total
+	},
+}).Parse(htmlSource))
+
+// renderHTML writes a single HTML file that needs nothing but a browser: the styles, the script
+// sorting the tables and the charts are all inline
+func renderHTML(w io.Writer, report ScanReport) error {
+	page := htmlPage{
+		Generated:  time.Now().UTC().Format("2006-01-02 15:04 UTC"),
+		Report:     report,
+		Severities: severities,
+		Services:   serviceSections(report),
+		Charts:     instanceTypeCharts(report),
+		Stacked:    storageClassCharts(report),
+		Suppressed: report.suppressed(),
+		Expired:    report.expiredSuppressions(),
+	}
+	if err := htmlTemplate.Execute(w, page); err != nil {
+		return fmt.Errorf("error with writing html report: %v", err)
+	}
+	return nil
+}
+
+const htmlSource = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>AWS Vitals Monitor report</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 0; color: #222; background: #f6f7f9; }
+header { background: #232f3e; color: #fff; padding: 16px 32px; }
+header h1 { margin: 0; font-size: 22px; }
+header p { margin: 4px 0 0; color: #c9d1d9; font-size: 13px; }
+main { padding: 16px 32px 48px; }
+h2 { font-size: 18px; margin: 28px 0 12px; }
+.banner { background: #fff; border: 1px solid #dde1e6; border-radius: 6px; padding: 12px 16px; margin-bottom: 8px; }
+.banner dl { display: grid; grid-template-columns: max-content auto; gap: 4px 16px; margin: 0; }
+.banner dt { font-weight: 600; }
+.banner dd { margin: 0; }
+.error { color: #b42318; }
+.cards { display: flex; gap: 12px; flex-wrap: wrap; }
+.card { background: #fff; border: 1px solid #dde1e6; border-left: 6px solid; border-radius: 6px; padding: 10px 16px; min-width: 110px; }
+.card .count { font-size: 26px; font-weight: 700; }
+.sev-critical { border-color: #7a0916; } .sev-high { border-color: #d92d20; } .sev-medium { border-color: #f79009; }
+.sev-low { border-color: #fdb022; } .sev-info { border-color: #98a2b3; }
+.badge { display: inline-block; border-radius: 10px; padding: 1px 8px; font-size: 12px; color: #fff; }
+.badge.critical { background: #7a0916; } .badge.high { background: #d92d20; } .badge.medium { background: #f79009; }
+.badge.low { background: #fdb022; color: #222; } .badge.info { background: #98a2b3; }
+table { border-collapse: collapse; width: 100%; background: #fff; margin: 8px 0; font-size: 13px; }
+th, td { border: 1px solid #dde1e6; padding: 5px 8px; text-align: left; vertical-align: top; }
+th { background: #eef0f3; cursor: pointer; user-select: none; white-space: nowrap; }
+th.sorted-asc::after { content: " ▲"; } th.sorted-desc::after { content: " ▼"; }
+td.num { text-align: right; }
+details { background: #fff; border: 1px solid #dde1e6; border-radius: 6px; margin-bottom: 10px; padding: 8px 12px; }
+summary { cursor: pointer; font-weight: 600; }
+summary .badge { margin-left: 4px; }
+.chart { background: #fff; border: 1px solid #dde1e6; border-radius: 6px; padding: 12px 16px; margin-bottom: 12px; }
+.chart h3 { font-size: 14px; margin: 0 0 8px; }
+.bar-row { display: grid; grid-template-columns: 220px auto 60px; gap: 8px; align-items: center; font-size: 13px; margin: 3px 0; }
+.bar-row .label { overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+.bar-track { background: #eef0f3; height: 14px; border-radius: 3px; overflow: hidden; display: flex; }
+.bar-track svg { display: block; }
+.legend { display: flex; gap: 12px; flex-wrap: wrap; font-size: 12px; margin-bottom: 8px; }
+.swatch { display: inline-block; width: 10px; height: 10px; margin-right: 4px; }
+</style>
+</head>
+<body>
+<header>
+<h1>AWS Vitals Monitor report</h1>
+<p>Generated {{.Generated}} · {{.Report.Summary.TotalAccounts}} account(s) · {{.Report.Summary.TotalFindings}} findings{{if .Report.Summary.TotalSuppressed}} · {{.Report.Summary.TotalSuppressed}} suppressed{{end}}{{if .Report.Summary.TotalBaselined}} · {{.Report.Summary.TotalBaselined}} in the baseline{{end}}</p>
+</header>
+<main>
+<h2>Account information</h2>
+{{range .Report.Accounts}}
+<div class="banner">
+<dl>
+<dt>Account ID</dt><dd>{{.AccountId}}</dd>
+<dt>Account Alias</dt><dd>{{.AccountAlias}}</dd>
+{{if .Error}}<dt>Status</dt><dd class="error">Not scanned: {{.Error}}</dd>
+{{else}}<dt>Regions</dt><dd>{{regions .}}</dd>
+<dt>Findings</dt><dd>{{.Summary.TotalFindings}}</dd>{{end}}
+</dl>
+</div>
+{{end}}
+
+<h2>Severity summary</h2>
+<div class="cards">
+{{range $s := .Severities}}<div class="card sev-{{$s}}"><div>{{upper $s}}</div><div class="count">{{index $.Report.Summary.FindingsBySeverity $s}}</div></div>
+{{end}}
+</div>
+<table class="sortable">
+<thead><tr><th>Service</th>{{range .Severities}}<th>{{upper .}}</th>{{end}}<th>Total</th></tr></thead>
+<tbody>
+{{range .Services}}{{$counts := .Counts}}<tr><td>{{.Service}}</td>{{range $.Severities}}<td class="num">{{index $counts .}}</td>{{end}}<td class="num">{{total $counts}}</td></tr>
+{{end}}
+</tbody>
+</table>
+
+<h2>Findings by service</h2>
+{{if not .Services}}<p>No findings ✅</p>{{end}}
+{{range .Services}}{{$counts := .Counts}}
+<details>
+<summary>{{.Service}} ({{len .Findings}}){{range $.Severities}}{{if index $counts .}} <span class="badge {{.}}">{{index $counts .}} {{.}}</span>{{end}}{{end}}</summary>
+<table class="sortable">
+<thead><tr><th>Severity</th><th>Check</th><th>Account</th><th>Region</th><th>Resource</th><th>Message</th></tr></thead>
+<tbody>
+{{range .Findings}}<tr><td data-sort="{{rank .Severity}}"><span class="badge {{.Severity}}">{{.Severity}}</span></td><td>{{.CheckID}}</td><td>{{.AccountID}}</td><td>{{.Region}}</td><td title="{{.ResourceARN}}">{{.ResourceID}}</td><td>{{.Message}}</td></tr>
+{{end}}
+</tbody>
+</table>
+</details>
+{{end}}
+
+{{if or .Charts .Stacked}}<h2>Charts</h2>{{end}}
+{{range .Charts}}
+<div class="chart">
+<h3>{{.Title}}</h3>
+{{range .Bars}}<div class="bar-row"><span class="label">{{.Label}}</span><span class="bar-track"><svg width="100%" height="14" preserveAspectRatio="none" viewBox="0 0 100 14"><rect width="{{percent .Percent}}" height="14" fill="{{.Color}}"></rect></svg></span><span>{{percent .Percent}}%</span></div>
+{{end}}
+</div>
+{{end}}
+{{range .Stacked}}
+<div class="chart">
+<h3>{{.Title}}</h3>
+<div class="legend">{{range .Legend}}<span><span class="swatch" style="background: {{.Color}}"></span>{{.Label}}</span>{{end}}</div>
+{{range .Rows}}<div class="bar-row"><span class="label" title="{{.Label}}">{{.Label}}</span><span class="bar-track"><svg width="100%" height="14" preserveAspectRatio="none" viewBox="0 0 100 14">{{$x := 0.0}}{{range .Segments}}<rect x="{{percent $x}}" width="{{percent .Percent}}" height="14" fill="{{.Color}}"><title>{{.Label}} {{percent .Percent}}%</title></rect>{{$x = add $x .Percent}}{{end}}</svg></span><span></span></div>
+{{end}}
+</div>
+{{end}}
+
+{{if .Suppressed}}
+<h2>Suppressed findings</h2>
+<table class="sortable">
+<thead><tr><th>Check</th><th>Account</th><th>Region</th><th>Resource</th><th>Message</th><th>Reason</th><th>Expires</th></tr></thead>
+<tbody>
+{{range .Suppressed}}<tr><td>{{.Finding.CheckID}}</td><td>{{.Finding.AccountID}}</td><td>{{.Finding.Region}}</td><td>{{.Finding.ResourceID}}</td><td>{{.Finding.Message}}</td><td>{{.Suppression.Reason}}</td><td>{{.Suppression.Expires}}</td></tr>
+{{end}}
+</tbody>
+</table>
+{{end}}
+{{if .Expired}}
+<h2>Expired suppressions</h2>
+<table class="sortable">
+<thead><tr><th>Check</th><th>Resource</th><th>Expired</th><th>Reason</th></tr></thead>
+<tbody>
+{{range .Expired}}<tr><td>{{.Finding.CheckID}}</td><td>{{.Finding.ResourceID}}</td><td>{{.Suppression.Expires}}</td><td>{{.Suppression.Reason}}</td></tr>
+{{end}}
+</tbody>
+</table>
+{{end}}
+</main>
+<script>
+// Sort a table by the clicked column, numerically when every cell of the column is a number
+document.querySelectorAll("table.sortable th").forEach(function (th) {
+  th.addEventListener("click", function () {
+    var table = th.closest("table");
+    var tbody = table.tBodies[0];
+    var index = Array.prototype.indexOf.call(th.parentNode.children, th);
+    var ascending = !th.classList.contains("sorted-asc");
+    table.querySelectorAll("th").forEach(function (other) { other.classList.remove("sorted-asc", "sorted-desc"); });
+    th.classList.add(ascending ? "sorted-asc" : "sorted-desc");
+    var value = function (row) {
+      var cell = row.children[index];
+      return cell.hasAttribute("data-sort") ? cell.getAttribute("data-sort") : cell.textContent.trim();
+    };
+    var rows = Array.prototype.slice.call(tbody.rows);
+    var numeric = rows.every(function (row) { return value(row) !== "" && !isNaN(value(row)); });
+    rows.sort(function (a, b) {
+      var x = value(a), y = value(b);
+      var order = numeric ? x - y : x.localeCompare(y);
+      return ascending ? order : -order;
+    });
+    rows.forEach(function (row) { tbody.appendChild(row); });
+  });
+});
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// JUnit XML as understood by Jenkins and GitLab: every check is a test suite and every resource
+// it evaluated a test case, failing when the resource has findings.
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+
+	seconds float64
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func (s *junitTestSuite) add(testCase junitTestCase) {
+	s.Cases = append(s.Cases, testCase)
+	s.Tests++
+	if testCase.Failure != nil {
+		s.Failures++
+	}
+	if testCase.Error != nil {
+		s.Errors++
+	}
+}
+
+// junitCases turns the results of one check in one region into test cases. Resources the check
+// evaluated without findings pass; findings of severity info are informational and pass too,
+// with their message as output.
+func junitCases(c Check, region MasterStructure, className string) []junitTestCase {
+	id := c.ID()
+	if err, ok := region.Errors[id]; ok {
+		return []junitTestCase{{
+			Name:      c.Description(),
+			ClassName: className,
+			Error:     &junitProblem{Message: err, Type: "error", Text: err},
+		}}
+	}
+
+	// Group the findings by resource, keeping the order they were reported in
+	var order []string
+	byResource := make(map[string][]Finding)
+	add := func(resource string) {
+		if _, ok := byResource[resource]; !ok {
+			byResource[resource] = nil
+			order = append(order, resource)
+		}
+	}
+	for _, resource := range region.Evaluated[id] {
+		add(resource)
+	}
+	for _, f := range region.Results {
+		if f.CheckID != id {
+			continue
+		}
+		add(f.ResourceID)
+		byResource[f.ResourceID] = append(byResource[f.ResourceID], f)
+	}
+	if len(order) == 0 {
+		return []junitTestCase{{Name: c.Description(), ClassName: className}}
+	}
+
+	cases := make([]junitTestCase, 0, len(order))
+	for _, resource := range order {
+		testCase := junitTestCase{Name: resource, ClassName: className}
+		if resource == "" {
+			testCase.Name = c.Description()
+		}
+		var failing, info []string
+		severity := SeverityInfo
+		for _, f := range byResource[resource] {
+			if f.Severity == SeverityInfo {
+				info = append(info, f.Message)
+				continue
+			}
+			failing = append(failing, f.Message)
+			if f.Severity.rank() > severity.rank() {
+				severity = f.Severity
+			}
+		}
+		if len(failing) > 0 {
+			testCase.Failure = &junitProblem{
+				Message: failing[0],
+				Type:    string(severity),
+				Text:    strings.Join(failing, "\n"),
+			}
+		}
+		testCase.SystemOut = strings.Join(info, "\n")
+		cases = append(cases, testCase)
+	}
+	return cases
+}
+
+func renderJUnit(w io.Writer, report ScanReport) error {
+	suites := junitTestSuites{Name: "avm"}
+	index := make(map[string]int)
+	var seconds float64
+	for _, account := range report.Accounts {
+		for _, region := range account.Regions {
+			className := account.AccountId + "." + region.AccountInformation.RegionCode
+			for _, id := range region.ChecksRun {
+				c := lookupCheck(id)
+				if c == nil {
+					continue
+				}
+				i, ok := index[id]
+				if !ok {
+					i = len(suites.Suites)
+					index[id] = i
+					suites.Suites = append(suites.Suites, junitTestSuite{Name: id})
+				}
+				suite := &suites.Suites[i]
+				for _, testCase := range junitCases(c, region, className) {
+					suite.add(testCase)
+				}
+				suite.seconds += region.Durations[id]
+				seconds += region.Durations[id]
+			}
+		}
+	}
+	for i := range suites.Suites {
+		suite := &suites.Suites[i]
+		suite.Time = fmt.Sprintf("%.3f", suite.seconds)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+	}
+	suites.Time = fmt.Sprintf("%.3f", seconds)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "    ")
+	if err := encoder.Encode(suites); err != nil {
+		return fmt.Errorf("error with writing junit data: %v", err)
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
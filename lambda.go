@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 
 	"encoding/json"
@@ -12,12 +13,12 @@ import (
 	"github.com/aws/aws-sdk-go/service/servicequotas"
 )
 
-func listLambdaFunctions(lambdaClient *lambda.Lambda) ([]*lambda.FunctionConfiguration, error) {
+func listLambdaFunctions(ctx context.Context, lambdaClient *lambda.Lambda) ([]*lambda.FunctionConfiguration, error) {
 	var functions []*lambda.FunctionConfiguration
 	input := &lambda.ListFunctionsInput{}
 
 	for {
-		result, err := lambdaClient.ListFunctions(input)
+		result, err := lambdaClient.ListFunctionsWithContext(ctx, input)
 		if err != nil {
 			return nil, err
 		}
@@ -36,32 +37,17 @@ func listLambdaFunctions(lambdaClient *lambda.Lambda) ([]*lambda.FunctionConfigu
 	return functions, nil
 }
 
-func outdatedFunctionRuntimeCheck(lambdaClient *lambda.Lambda, functionArn string) {
-	input := &lambda.GetFunctionInput{
-		FunctionName: aws.String(functionArn),
-	}
-
-	result, err := lambdaClient.GetFunction(input)
-
-	if err != nil {
-		fmt.Printf("Error getting function configuration for %s: %s\n", functionArn, err)
-		return
-	}
-
-	function := result.Configuration
-
-	// Check for outdated runtime environments
-	outdatedRuntimes, err := GetDeprecatedRuntimes()
-	if err != nil {
-		fmt.Println("Error:", err)
-		return
-	}
-
-	for _, outdatedRuntime := range outdatedRuntimes {
-		if function.Runtime != nil && *function.Runtime == outdatedRuntime {
-			fmt.Printf("\nOutdated runtime detected for function %s: %s\n", *function.FunctionName, *function.Runtime)
+// outdatedFunctionRuntimeCheck returns the functions whose runtime is in the deprecated list
+func outdatedFunctionRuntimeCheck(functions []*lambda.FunctionConfiguration, outdatedRuntimes []string) []*lambda.FunctionConfiguration {
+	var outdated []*lambda.FunctionConfiguration
+	for _, function := range functions {
+		for _, outdatedRuntime := range outdatedRuntimes {
+			if function.Runtime != nil && *function.Runtime == outdatedRuntime {
+				outdated = append(outdated, function)
+			}
 		}
 	}
+	return outdated
 }
 
 // DeprecatedRuntimesResponse struct to map the JSON response
@@ -70,9 +56,13 @@ type DeprecatedRuntimesResponse struct {
 }
 
 // GetDeprecatedRuntimes fetches a list of deprecated runtimes from the specified URL
-func GetDeprecatedRuntimes() ([]string, error) {
+func GetDeprecatedRuntimes(ctx context.Context) ([]string, error) {
 	url := "https://lambda-deprecated-runtimes-atzlvbq4rq-uc.a.run.app"
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching deprecated runtimes: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching deprecated runtimes: %v", err)
 	}
@@ -95,13 +85,15 @@ func GetDeprecatedRuntimes() ([]string, error) {
 
 	return response.DeprecatedRuntimes, nil
 }
-func calculateLambdaStorage(functionsConfigs []*lambda.FunctionConfiguration, quotasClient *servicequotas.ServiceQuotas) error {
-	quotaResp, err := quotasClient.GetServiceQuota(&servicequotas.GetServiceQuotaInput{
+
+// calculateLambdaStorage returns the total code size of the functions and the account's code storage quota, both in GB
+func calculateLambdaStorage(ctx context.Context, functionsConfigs []*lambda.FunctionConfiguration, quotasClient *servicequotas.ServiceQuotas) (float64, float64, error) {
+	quotaResp, err := quotasClient.GetServiceQuotaWithContext(ctx, &servicequotas.GetServiceQuotaInput{
 		QuotaCode:   aws.String("L-2ACBD22F"),
 		ServiceCode: aws.String("lambda"),
 	})
 	if err != nil {
-		return fmt.Errorf("error getting service quota: %v", err)
+		return 0, 0, fmt.Errorf("error getting service quota: %v", err)
 	}
 	quotaGB := *quotaResp.Quota.Value
 	var totalSizeBytes int64
@@ -109,9 +101,79 @@ func calculateLambdaStorage(functionsConfigs []*lambda.FunctionConfiguration, qu
 		totalSizeBytes += *functionConfig.CodeSize
 	}
 	totalSizeGB := float64(totalSizeBytes) / 1024 / 1024 / 1024
-	fmt.Printf("Total size of Lambda functions: %.2f GB\n", totalSizeGB)
-	fmt.Printf("Lambda storage quota: %.2f GB\n", quotaGB)
-	fmt.Printf("Percentage used: %.2f%%\n", (totalSizeGB/quotaGB)*100)
 
-	return nil
+	return totalSizeGB, quotaGB, nil
+}
+
+var (
+	outdatedRuntimesCheck = &basicCheck{
+		id:          "lambda-outdated-runtimes",
+		service:     "lambda",
+		severity:    SeverityMedium,
+		category:    CategorySecurity,
+		description: "Lambda functions with outdated runtimes",
+		help:        "Functions on deprecated runtimes no longer get security patches. Move them to a supported runtime version.",
+	}
+	lambdaStorageCheck = &basicCheck{
+		id:          "lambda-storage-usage",
+		service:     "lambda",
+		severity:    SeverityInfo,
+		category:    CategoryReliability,
+		description: "Lambda storage usage",
+		help:        "Code storage is limited per region. Delete unused function versions and layers, or ask for a quota increase.",
+	}
+)
+
+func init() {
+	outdatedRuntimesCheck.run = runOutdatedRuntimesCheck
+	lambdaStorageCheck.run = runLambdaStorageCheck
+
+	registerCheck(outdatedRuntimesCheck)
+	registerCheck(lambdaStorageCheck)
+}
+
+func runOutdatedRuntimesCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	lambdaFunctions, err := listLambdaFunctions(ctx, clients.Lambda)
+	if err != nil {
+		return nil, err
+	}
+	outdatedRuntimes, err := GetDeprecatedRuntimes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	functionARNs := make([]string, 0, len(lambdaFunctions))
+	for _, function := range lambdaFunctions {
+		functionARNs = append(functionARNs, aws.StringValue(function.FunctionArn))
+	}
+	report.RecordEvaluated(outdatedRuntimesCheck.id, functionARNs)
+	var findings []Finding
+	var outdated []OutdatedFunction
+	for _, function := range outdatedFunctionRuntimeCheck(lambdaFunctions, outdatedRuntimes) {
+		outdated = append(outdated, OutdatedFunction{FunctionName: *function.FunctionName, Runtime: *function.Runtime})
+		findings = append(findings, outdatedRuntimesCheck.finding(aws.StringValue(function.FunctionArn),
+			"Outdated runtime detected for function %s: %s", *function.FunctionName, *function.Runtime))
+	}
+	report.Update(func(f *Findings) {
+		f.LambdaFunctions.TotalAnalyzed = len(lambdaFunctions)
+		f.LambdaFunctions.OutdatedRuntimes = outdated
+	})
+	return findings, nil
+}
+
+func runLambdaStorageCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	lambdaFunctions, err := listLambdaFunctions(ctx, clients.Lambda)
+	if err != nil {
+		return nil, err
+	}
+	totalSizeGB, quotaGB, err := calculateLambdaStorage(ctx, lambdaFunctions, clients.ServiceQuotas)
+	if err != nil {
+		return nil, err
+	}
+	report.Update(func(f *Findings) {
+		f.LambdaFunctions.TotalAnalyzed = len(lambdaFunctions)
+		f.LambdaFunctions.StorageUsedGB = totalSizeGB
+		f.LambdaFunctions.StorageQuotaGB = quotaGB
+	})
+	return []Finding{lambdaStorageCheck.finding("",
+		"Total size of Lambda functions: %.2f GB of %.2f GB quota (%.2f%% used)", totalSizeGB, quotaGB, (totalSizeGB/quotaGB)*100)}, nil
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// markdownCell escapes the characters that would end a table cell or row
+func markdownCell(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\\`)
+	text = strings.ReplaceAll(text, "|", `\|`)
+	text = strings.ReplaceAll(text, "\r", "")
+	return strings.ReplaceAll(text, "\n", "<br>")
+}
+
+func markdownRow(b *strings.Builder, cells ...string) {
+	b.WriteString("|")
+	for _, cell := range cells {
+		b.WriteString(" " + markdownCell(cell) + " |")
+	}
+	b.WriteString("\n")
+}
+
+func markdownHeader(b *strings.Builder, cells ...string) {
+	markdownRow(b, cells...)
+	b.WriteString("|" + strings.Repeat(" --- |", len(cells)) + "\n")
+}
+
+// renderMarkdown writes the report as a page for a wiki: the accounts scanned, the findings count
+// by service and severity and a table of findings per service
+func renderMarkdown(w io.Writer, report ScanReport) error {
+	var b strings.Builder
+	b.WriteString("# AWS Vitals Monitor report\n\n")
+
+	markdownHeader(&b, "Account", "Alias", "Regions", "Findings")
+	for _, account := range report.Accounts {
+		if account.Error != "" {
+			markdownRow(&b, account.AccountId, account.AccountAlias, "-", "not scanned: "+account.Error)
+			continue
+		}
+		codes := make([]string, 0, len(account.Regions))
+		for _, region := range account.Regions {
+			codes = append(codes, region.AccountInformation.RegionCode)
+		}
+		markdownRow(&b, account.AccountId, account.AccountAlias, strings.Join(codes, ", "), fmt.Sprint(account.Summary.TotalFindings))
+	}
+
+	sections := serviceSections(report)
+	b.WriteString("\n## Findings by severity\n\n")
+	header := []string{"Service"}
+	for _, severity := range severities {
+		header = append(header, strings.ToUpper(string(severity)))
+	}
+	markdownHeader(&b, append(header, "Total")...)
+	for _, section := range sections {
+		row := []string{section.Service}
+		total := 0
+		for _, severity := range severities {
+			total += section.Counts[severity]
+			row = append(row, fmt.Sprint(section.Counts[severity]))
+		}
+		markdownRow(&b, append(row, fmt.Sprint(total))...)
+	}
+	row := []string{"**Total**"}
+	for _, severity := range severities {
+		row = append(row, fmt.Sprint(report.Summary.FindingsBySeverity[severity]))
+	}
+	markdownRow(&b, append(row, fmt.Sprint(report.Summary.TotalFindings))...)
+	if report.Summary.TotalBaselined > 0 {
+		row := []string{"In the baseline"}
+		for _, severity := range severities {
+			row = append(row, fmt.Sprint(report.Summary.BaselinedBySeverity[severity]))
+		}
+		markdownRow(&b, append(row, fmt.Sprint(report.Summary.TotalBaselined))...)
+	}
+
+	for _, section := range sections {
+		fmt.Fprintf(&b, "\n## %s\n\n", section.Service)
+		markdownHeader(&b, "Severity", "Check", "Account", "Region", "Resource", "Message", "Monthly cost (USD)", "Monthly savings (USD)")
+		for _, f := range section.Findings {
+			markdownRow(&b, string(f.Severity), f.CheckID, f.AccountID, f.Region, f.ResourceID, f.Message,
+				formatMonthlyCost(f.MonthlyCost), formatMonthlyCost(f.MonthlySavings))
+		}
+	}
+
+	if suppressed := report.suppressed(); len(suppressed) > 0 {
+		b.WriteString("\n## Suppressed findings\n\n")
+		markdownHeader(&b, "Check", "Resource", "Message", "Reason", "Expires")
+		for _, s := range suppressed {
+			markdownRow(&b, s.Finding.CheckID, s.Finding.ResourceID, s.Finding.Message, s.Suppression.Reason, s.Suppression.Expires)
+		}
+	}
+	if expired := report.expiredSuppressions(); len(expired) > 0 {
+		b.WriteString("\n## Expired suppressions\n\n")
+		markdownHeader(&b, "Check", "Resource", "Expired on", "Reason")
+		for _, e := range expired {
+			markdownRow(&b, e.Finding.CheckID, e.Finding.ResourceID, e.Suppression.Expires, e.Suppression.Reason)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// scanMetrics exposes the outcome of the last scan of 'avm serve' to Prometheus. Gauges are reset
+// after every scan, so a region or check that was not scanned has no series rather than a stale
+// one; the error counter keeps counting across scans.
+type scanMetrics struct {
+	registry *prometheus.Registry
+
+	findings          *prometheus.GaugeVec
+	orphanedEBSGB     *prometheus.GaugeVec
+	orphanedEBSCost   *prometheus.GaugeVec
+	lambdaStorageUsed *prometheus.GaugeVec
+	unassociatedEIPs  *prometheus.GaugeVec
+	checkDuration     *prometheus.GaugeVec
+	checkErrors       *prometheus.CounterVec
+	lastScan          prometheus.Gauge
+}
+
+func newScanMetrics() *scanMetrics {
+	regionLabels := []string{"account", "region"}
+	checkLabels := []string{"check", "account", "region"}
+	m := &scanMetrics{
+		registry: prometheus.NewRegistry(),
+		findings: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "avm_findings",
+			Help: "Number of findings of the last scan, suppressed and baselined findings excluded.",
+		}, []string{"check", "severity", "account", "region"}),
+		orphanedEBSGB: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "avm_orphaned_ebs_gb",
+			Help: "Total size of the EBS volumes not attached to any instance.",
+		}, regionLabels),
+		orphanedEBSCost: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "avm_orphaned_ebs_monthly_cost_usd",
+			Help: "Approximate monthly cost of the EBS volumes not attached to any instance.",
+		}, regionLabels),
+		lambdaStorageUsed: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "avm_lambda_storage_used_ratio",
+			Help: "Share of the Lambda code storage quota in use, from 0 to 1.",
+		}, regionLabels),
+		unassociatedEIPs: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "avm_unassociated_eips",
+			Help: "Number of elastic IPs not associated with any instance.",
+		}, regionLabels),
+		checkDuration: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "avm_check_duration_seconds",
+			Help: "How long every check took in the last scan.",
+		}, checkLabels),
+		checkErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "avm_check_errors_total",
+			Help: "Number of times a check failed to run.",
+		}, checkLabels),
+		lastScan: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "avm_last_scan_timestamp_seconds",
+			Help: "Unix time the last successful scan finished at.",
+		}),
+	}
+	m.registry.MustRegister(m.findings, m.orphanedEBSGB, m.orphanedEBSCost, m.lambdaStorageUsed,
+		m.unassociatedEIPs, m.checkDuration, m.checkErrors, m.lastScan)
+	return m
+}
+
+// ran reports whether the check ran in region without failing, and so filled in its findings
+func ran(region MasterStructure, checkID string) bool {
+	_, failed := region.Errors[checkID]
+	return contains(region.ChecksRun, checkID) && !failed
+}
+
+// observe replaces the gauges with the outcome of report
+func (m *scanMetrics) observe(report ScanReport, finished time.Time) {
+	for _, gauge := range []*prometheus.GaugeVec{m.findings, m.orphanedEBSGB, m.orphanedEBSCost, m.lambdaStorageUsed, m.unassociatedEIPs, m.checkDuration} {
+		gauge.Reset()
+	}
+	for _, f := range report.findings() {
+		m.findings.WithLabelValues(f.CheckID, string(f.Severity), f.AccountID, f.Region).Inc()
+	}
+	for _, account := range report.Accounts {
+		for _, region := range account.Regions {
+			code := region.AccountInformation.RegionCode
+			for _, id := range region.ChecksRun {
+				m.checkDuration.WithLabelValues(id, account.AccountId, code).Set(region.Durations[id])
+			}
+			for id := range region.Errors {
+				m.checkErrors.WithLabelValues(id, account.AccountId, code).Inc()
+			}
+
+			f := region.Findings
+			if ran(region, orphanedVolumesCheck.id) {
+				m.orphanedEBSGB.WithLabelValues(account.AccountId, code).Set(float64(f.OrphanedEBSVolumes.TotalSizeGB))
+				m.orphanedEBSCost.WithLabelValues(account.AccountId, code).Set(f.OrphanedEBSVolumes.TotalMonthlyCost)
+			}
+			if ran(region, lambdaStorageCheck.id) && f.LambdaFunctions.StorageQuotaGB > 0 {
+				m.lambdaStorageUsed.WithLabelValues(account.AccountId, code).Set(f.LambdaFunctions.StorageUsedGB / f.LambdaFunctions.StorageQuotaGB)
+			}
+			if ran(region, unassociatedEIPsCheck.id) {
+				m.unassociatedEIPs.WithLabelValues(account.AccountId, code).Set(float64(len(f.UnassociatedElasticIPs)))
+			}
+		}
+	}
+	m.lastScan.Set(float64(finished.Unix()))
+}
+
+func (m *scanMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// webhookPayloadVersion is sent with every JSON webhook, so that receivers can tell when the
+// payload changes
+const webhookPayloadVersion = 1
+
+// defaultNotifyTop is how many findings a notification lists by default
+const defaultNotifyTop = 10
+
+// notifyOptions tell where to post a summary of every scan and which findings it covers
+type notifyOptions struct {
+	// SlackWebhook is a Slack incoming webhook URL, if set
+	SlackWebhook string
+	// Webhook receives the summary as a webhookPayload, if set
+	Webhook string
+	// OnlyNew leaves out the findings the previous scan already had
+	OnlyNew bool
+	// MinSeverity leaves out less severe findings
+	MinSeverity Severity
+	// Top is how many findings the summary lists, most severe first
+	Top int
+}
+
+func (o notifyOptions) enabled() bool {
+	return o.SlackWebhook != "" || o.Webhook != ""
+}
+
+func (o notifyOptions) validate() error {
+	for flag, value := range map[string]string{"--slack-webhook": o.SlackWebhook, "--webhook": o.Webhook} {
+		if value == "" {
+			continue
+		}
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid %s, expected an http or https URL", flag)
+		}
+	}
+	if o.Top < 0 {
+		return fmt.Errorf("--notify-top must not be negative, got %d", o.Top)
+	}
+	return nil
+}
+
+// selected returns the findings of n the options cover, most severe first
+func (o notifyOptions) selected(n notification) []Finding {
+	findings := n.Report.findings()
+	if o.OnlyNew {
+		findings = n.New
+	}
+	var selected []Finding
+	for _, f := range findings {
+		if o.MinSeverity == "" || f.Severity.atLeast(o.MinSeverity) {
+			selected = append(selected, f)
+		}
+	}
+	sortFindings(selected)
+	return selected
+}
+
+// newNotifiers returns the notifiers configured in o
+func newNotifiers(o notifyOptions) []notifier {
+	client := &http.Client{Timeout: 15 * time.Second}
+	var notifiers []notifier
+	if o.SlackWebhook != "" {
+		notifiers = append(notifiers, webhookNotifier{name: "Slack", url: o.SlackWebhook, client: client, options: o, payload: slackPayload})
+	}
+	if o.Webhook != "" {
+		notifiers = append(notifiers, webhookNotifier{name: "the webhook", url: o.Webhook, client: client, options: o, payload: jsonPayload})
+	}
+	return notifiers
+}
+
+// webhookNotifier posts a summary of the findings selected by its options, and nothing when no
+// finding is selected
+type webhookNotifier struct {
+	name    string
+	url     string
+	client  *http.Client
+	options notifyOptions
+	payload func(summary notificationSummary) interface{}
+}
+
+func (w webhookNotifier) Notify(ctx context.Context, n notification) error {
+	findings := w.options.selected(n)
+	if len(findings) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(w.payload(summarize(n, w.options, findings)))
+	if err != nil {
+		return fmt.Errorf("failed to notify %s: %v", w.name, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to notify %s: %v", w.name, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "aws-vitals-monitor")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		// The error holds the URL, which is a secret for Slack webhooks
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		return fmt.Errorf("failed to notify %s: %v", w.name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to notify %s: %s: %s", w.name, resp.Status, strings.TrimSpace(string(text)))
+	}
+	return nil
+}
+
+// notificationRegion counts the findings notified about in one region of one account
+type notificationRegion struct {
+	AccountID          string           `json:"accountId"`
+	AccountAlias       string           `json:"accountAlias,omitempty"`
+	Region             string           `json:"region"`
+	TotalFindings      int              `json:"totalFindings"`
+	FindingsBySeverity map[Severity]int `json:"findingsBySeverity"`
+}
+
+// notificationSummary is what every notifier posts, in its own format
+type notificationSummary struct {
+	Run                historyRun
+	OnlyNew            bool
+	MinSeverity        Severity
+	TotalFindings      int
+	FindingsBySeverity map[Severity]int
+	Regions            []notificationRegion
+	Top                []Finding
+}
+
+// summarize counts findings by account and region, findings being sorted most severe first
+func summarize(n notification, o notifyOptions, findings []Finding) notificationSummary {
+	aliases := make(map[string]string)
+	for _, account := range n.Report.Accounts {
+		aliases[account.AccountId] = account.AccountAlias
+	}
+	summary := notificationSummary{
+		Run:                n.Run,
+		OnlyNew:            o.OnlyNew,
+		MinSeverity:        o.MinSeverity,
+		TotalFindings:      len(findings),
+		FindingsBySeverity: make(map[Severity]int),
+	}
+	regions := make(map[string]*notificationRegion)
+	for _, f := range findings {
+		summary.FindingsBySeverity[f.Severity]++
+		key := f.AccountID + "/" + f.Region
+		region := regions[key]
+		if region == nil {
+			region = &notificationRegion{AccountID: f.AccountID, AccountAlias: aliases[f.AccountID], Region: f.Region, FindingsBySeverity: make(map[Severity]int)}
+			regions[key] = region
+		}
+		region.TotalFindings++
+		region.FindingsBySeverity[f.Severity]++
+	}
+	for _, region := range regions {
+		summary.Regions = append(summary.Regions, *region)
+	}
+	sort.Slice(summary.Regions, func(i, j int) bool {
+		a, b := summary.Regions[i], summary.Regions[j]
+		if a.AccountID != b.AccountID {
+			return a.AccountID < b.AccountID
+		}
+		return a.Region < b.Region
+	})
+	summary.Top = findings
+	if len(summary.Top) > o.Top {
+		summary.Top = summary.Top[:o.Top]
+	}
+	return summary
+}
+
+// webhookPayload is the body posted to --webhook, documented in the README
+type webhookPayload struct {
+	Version int `json:"version"`
+	// Run is the run the scan was saved as in the history, 0 when it was not saved
+	Run  uint64    `json:"run,omitempty"`
+	Time time.Time `json:"time"`
+	// OnlyNew tells whether only the findings the previous scan did not have are counted
+	OnlyNew bool `json:"onlyNew"`
+	// MinSeverity is the least severe finding counted, empty when every finding is
+	MinSeverity        Severity             `json:"minSeverity,omitempty"`
+	TotalFindings      int                  `json:"totalFindings"`
+	FindingsBySeverity map[Severity]int     `json:"findingsBySeverity"`
+	Regions            []notificationRegion `json:"regions"`
+	// TopFindings are the most severe findings counted, at most --notify-top of them
+	TopFindings []Finding `json:"topFindings"`
+}
+
+func jsonPayload(s notificationSummary) interface{} {
+	top := s.Top
+	if top == nil {
+		top = []Finding{}
+	}
+	return webhookPayload{
+		Version:            webhookPayloadVersion,
+		Run:                s.Run.ID,
+		Time:               time.Now().UTC(),
+		OnlyNew:            s.OnlyNew,
+		MinSeverity:        s.MinSeverity,
+		TotalFindings:      s.TotalFindings,
+		FindingsBySeverity: s.FindingsBySeverity,
+		Regions:            s.Regions,
+		TopFindings:        top,
+	}
+}
+
+// slackEscape escapes the characters Slack gives a meaning to in message text
+func slackEscape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+// severityCounts writes counts such as "2 critical, 1 low", most severe first
+func severityCounts(counts map[Severity]int) string {
+	var parts []string
+	for _, severity := range severities {
+		if counts[severity] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[severity], severity))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// slackPayload is a message for a Slack incoming webhook
+func slackPayload(s notificationSummary) interface{} {
+	var b strings.Builder
+	kind := "findings"
+	if s.OnlyNew {
+		kind = "new findings"
+	}
+	fmt.Fprintf(&b, "*AWS Vitals Monitor*: %d %s", s.TotalFindings, kind)
+	if s.MinSeverity != "" && s.MinSeverity != SeverityInfo {
+		fmt.Fprintf(&b, " of %s severity or above", s.MinSeverity)
+	}
+	fmt.Fprintf(&b, " (%s)\n", severityCounts(s.FindingsBySeverity))
+	for _, region := range s.Regions {
+		account := region.AccountID
+		if region.AccountAlias != "" {
+			account += " (" + region.AccountAlias + ")"
+		}
+		fmt.Fprintf(&b, "• %s %s: %s\n", slackEscape(account), region.Region, severityCounts(region.FindingsBySeverity))
+	}
+	if len(s.Top) > 0 {
+		b.WriteString("\n*Top findings*\n")
+		for _, f := range s.Top {
+			resource := ""
+			if f.ResourceID != "" {
+				resource = " `" + slackEscape(f.ResourceID) + "`"
+			}
+			fmt.Fprintf(&b, "• [%s] %s%s: %s\n", f.Severity, f.CheckID, resource, slackEscape(f.Message))
+		}
+		if more := s.TotalFindings - len(s.Top); more > 0 {
+			fmt.Fprintf(&b, "…and %d more\n", more)
+		}
+	}
+	if s.Run.ID != 0 {
+		fmt.Fprintf(&b, "\nSaved as run %d, see `avm diff` for details", s.Run.ID)
+	}
+	return struct {
+		Text string `json:"text"`
+	}{strings.TrimRight(b.String(), "\n")}
+}
+
+// notifyAll runs every notifier, returning the errors of the ones that failed
+func notifyAll(ctx context.Context, notifiers []notifier, n notification) []error {
+	var errs []error
+	for _, notifier := range notifiers {
+		if err := notifier.Notify(ctx, n); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// renderers write a finished report in one of the --output formats
+var renderers = map[string]func(w io.Writer, report ScanReport) error{
+	"text":     renderText,
+	"json":     renderJSON,
+	"sarif":    renderSARIF,
+	"junit":    renderJUnit,
+	"html":     renderHTML,
+	"csv":      renderCSV,
+	"markdown": renderMarkdown,
+}
+
+func outputFormatNames() []string {
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func renderJSON(w io.Writer, report ScanReport) error {
+	jsonData, err := json.MarshalIndent(report, "", "    ")
+	if err != nil {
+		return fmt.Errorf("error with parsing json data: %v", err)
+	}
+	_, err = fmt.Fprintln(w, string(jsonData))
+	return err
+}
+
+// renderText prints the totals that only make sense once every check is done. The findings
+// themselves are printed by printCheckResult while the scan runs.
+func renderText(w io.Writer, report ScanReport) error {
+	for _, account := range report.Accounts {
+		if account.Error != "" {
+			continue
+		}
+		for _, region := range account.Regions {
+			if len(account.Regions) > 1 || len(report.Accounts) > 1 {
+				fmt.Fprintf(w, "\n######## Totals for %s in %s ########\n", account.AccountId, region.AccountInformation.RegionCode)
+			}
+			renderRegionTotals(w, region.Findings)
+		}
+		if len(account.Regions) > 1 {
+			renderAccountSummary(w, account)
+		}
+	}
+	if len(report.Accounts) > 1 {
+		renderOrganizationSummary(w, report)
+	}
+	renderSeveritySummary(w, report)
+	renderSuppressed(w, report)
+	return nil
+}
+
+// renderSuppressed lists the suppressed findings and warns about suppressions that expired
+func renderSuppressed(w io.Writer, report ScanReport) {
+	if suppressed := report.suppressed(); len(suppressed) > 0 {
+		fmt.Fprintf(w, "\n######## Suppressed findings ########\n")
+		for _, s := range suppressed {
+			until := ""
+			if s.Suppression.Expires != "" {
+				until = ", until " + s.Suppression.Expires
+			}
+			fmt.Fprintf(w, "  - [%s] %s: %s (%s%s)\n", s.Finding.CheckID, s.Finding.ResourceID, s.Finding.Message, s.Suppression.Reason, until)
+		}
+	}
+	if expired := report.expiredSuppressions(); len(expired) > 0 {
+		fmt.Fprintf(w, "\n######## Expired suppressions ########\n")
+		for _, e := range expired {
+			fmt.Fprintf(w, "⚠️  [%s] %s: suppression expired on %s (%s)\n", e.Finding.CheckID, e.Finding.ResourceID, e.Suppression.Expires, e.Suppression.Reason)
+		}
+	}
+}
+
+// renderSeveritySummary prints a table of findings by service and severity
+func renderSeveritySummary(w io.Writer, report ScanReport) {
+	fmt.Fprintf(w, "\n######## Findings by severity ########\n")
+	services := make([]string, 0, len(report.Summary.FindingsByService))
+	for service := range report.Summary.FindingsByService {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(writer, "SERVICE")
+	for _, severity := range severities {
+		fmt.Fprintf(writer, "\t%s", strings.ToUpper(string(severity)))
+	}
+	fmt.Fprintln(writer, "\tTOTAL")
+	for _, service := range services {
+		fmt.Fprint(writer, service)
+		total := 0
+		for _, severity := range severities {
+			count := report.Summary.FindingsByService[service][severity]
+			total += count
+			fmt.Fprintf(writer, "\t%d", count)
+		}
+		fmt.Fprintf(writer, "\t%d\n", total)
+	}
+	fmt.Fprint(writer, "TOTAL")
+	for _, severity := range severities {
+		fmt.Fprintf(writer, "\t%d", report.Summary.FindingsBySeverity[severity])
+	}
+	fmt.Fprintf(writer, "\t%d\n", report.Summary.TotalFindings)
+	// Findings in the --baseline file are counted, but apart from the ones reported
+	if report.Summary.TotalBaselined > 0 {
+		fmt.Fprint(writer, "BASELINED")
+		for _, severity := range severities {
+			fmt.Fprintf(writer, "\t%d", report.Summary.BaselinedBySeverity[severity])
+		}
+		fmt.Fprintf(writer, "\t%d\n", report.Summary.TotalBaselined)
+	}
+	writer.Flush()
+}
+
+func renderRegionTotals(w io.Writer, f Findings) {
+	fmt.Fprintln(w)
+	if f.Snapshots.TotalAnalyzed > 0 {
+		fmt.Fprintf(w, " #### Analyzed number of snapshots: %d ####\n", f.Snapshots.TotalAnalyzed)
+	}
+	if f.SecurityGroups.TotalAnalyzed > 0 {
+		fmt.Fprintf(w, " #### Analyzed %d Security groups ####\n", f.SecurityGroups.TotalAnalyzed)
+	}
+	if len(f.OrphanedEBSVolumes.Volumes) > 0 {
+		fmt.Fprintf(w, " ####Total orphaned volume size: %d GB #### \n", f.OrphanedEBSVolumes.TotalSizeGB)
+		fmt.Fprintf(w, " ####Total approximate monthly cost of orphaned volumes: $%.2f ####\n", f.OrphanedEBSVolumes.TotalMonthlyCost)
+	}
+	if f.EBSOptimization.TotalMonthlySavings > 0 {
+		fmt.Fprintf(w, " ####Total approximate monthly savings of optimizing EBS volumes: $%.2f ####\n", f.EBSOptimization.TotalMonthlySavings)
+	}
+	if f.EBSOptimization.SkippedRecentlyCreated > 0 {
+		fmt.Fprintf(w, "Skipped *** %d *** io1 and io2 volumes because they were created within the specified time period\n", f.EBSOptimization.SkippedRecentlyCreated)
+	}
+	if f.InstancesAnalysis.SkippedRecentlyStarted > 0 {
+		fmt.Fprintf(w, "Skipped *** %d *** instances because they were started within the specified time period\n", f.InstancesAnalysis.SkippedRecentlyStarted)
+	}
+	if f.RDSInstances.TotalAnalyzed > 0 {
+		fmt.Fprintf(w, " #### Analyzed %d RDS Instances ####\n", f.RDSInstances.TotalAnalyzed)
+	}
+	if f.ReservedInstancePurchases > 0 {
+		fmt.Fprintf(w, " #### Found %d purchases of reserved instances ####\n", f.ReservedInstancePurchases)
+		for _, reservation := range f.ReservedInstances {
+			fmt.Fprintf(w, "  %s: %s\n", reservation.ReservedInstancesId, reservation.Description)
+		}
+	}
+	if distribution := f.InstanceTypeDistribution; distribution.Count > 0 {
+		total := float64(distribution.Count)
+		fmt.Fprintf(w, " #### On-Demand Instances: %d (%.2f%%), Spot Instances: %d (%.2f%%) ####\n",
+			distribution.OnDemandCount, float64(distribution.OnDemandCount)/total*100, distribution.SpotCount, float64(distribution.SpotCount)/total*100)
+		types := make([]string, 0, len(distribution.Types))
+		for instanceType := range distribution.Types {
+			types = append(types, instanceType)
+		}
+		sort.Slice(types, func(i, j int) bool {
+			pi, pj := parsePercentage(distribution.Types[types[i]]), parsePercentage(distribution.Types[types[j]])
+			if pi != pj {
+				return pi > pj
+			}
+			return types[i] < types[j]
+		})
+		for _, instanceType := range types {
+			percentage := parsePercentage(distribution.Types[instanceType])
+			fmt.Fprintf(w, "  Instance Type: %-20s: %s (%7.1f%%)\n", instanceType, instanceTypeBar(percentage), percentage)
+		}
+	}
+	if f.S3Buckets.BucketsWithoutLifecyclePolicyPercentage != "" {
+		printBanner(w, "Percentage of buckets without a lifecycle policy: "+f.S3Buckets.BucketsWithoutLifecyclePolicyPercentage)
+	}
+}
+
+// renderAccountSummary prints the number of findings in every region of the account
+func renderAccountSummary(w io.Writer, report AccountReport) {
+	fmt.Fprintf(w, "\n######## Account %s (%s): %d findings ########\n", report.AccountId, report.AccountAlias, report.Summary.TotalFindings)
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "REGION\tFINDINGS")
+	for _, region := range report.Regions {
+		code := region.AccountInformation.RegionCode
+		fmt.Fprintf(writer, "%s\t%d\n", code, report.Summary.FindingsByRegion[code])
+	}
+	writer.Flush()
+}
+
+// renderOrganizationSummary prints a table of findings by account and severity
+func renderOrganizationSummary(w io.Writer, report ScanReport) {
+	fmt.Fprintf(w, "\n######## %d accounts: %d findings ########\n", report.Summary.TotalAccounts, report.Summary.TotalFindings)
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(writer, "ACCOUNT\tALIAS")
+	for _, severity := range severities {
+		fmt.Fprintf(writer, "\t%s", strings.ToUpper(string(severity)))
+	}
+	fmt.Fprintln(writer, "\tTOTAL")
+	for _, account := range report.Accounts {
+		fmt.Fprintf(writer, "%s\t%s", account.AccountId, account.AccountAlias)
+		if account.Error != "" {
+			fmt.Fprintln(writer, strings.Repeat("\t-", len(severities)+1))
+			continue
+		}
+		for _, severity := range severities {
+			fmt.Fprintf(writer, "\t%d", account.Summary.FindingsBySeverity[severity])
+		}
+		fmt.Fprintf(writer, "\t%d\n", account.Summary.TotalFindings)
+	}
+	fmt.Fprint(writer, "TOTAL\t")
+	for _, severity := range severities {
+		fmt.Fprintf(writer, "\t%d", report.Summary.FindingsBySeverity[severity])
+	}
+	fmt.Fprintf(writer, "\t%d\n", report.Summary.TotalFindings)
+	writer.Flush()
+
+	for _, account := range report.Accounts {
+		if account.Error != "" {
+			fmt.Fprintf(w, "❌ %s was not scanned: %s\n", account.AccountId, account.Error)
+		}
+	}
+}
+
+func printBanner(w io.Writer, text string) {
+	border := strings.Repeat("=", len(text)+6)
+	fmt.Fprintf(w, "%s\n", border)
+	fmt.Fprintf(w, "== %s ==\n", text)
+	fmt.Fprintf(w, "%s\n", border)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// pricingFallbackRegion is the region whose prices are used for regions the price table lacks
+const pricingFallbackRegion = "us-east-1"
+
+// ebsPricesJSON is the price table built into avm. Update ebs-prices.json when AWS changes its
+// prices, or pass a copy with --ebs-prices.
+//
+//go:embed ebs-prices.json
+var ebsPricesJSON []byte
+
+var defaultEBSPrices = mustParseEBSPrices(ebsPricesJSON)
+
+// ebsPriceTable holds the monthly EBS prices of every region, see ebs-prices.json
+type ebsPriceTable struct {
+	Description string               `json:"description"`
+	Updated     string               `json:"updated"`
+	Regions     map[string]ebsPrices `json:"regions"`
+}
+
+// ebsPrices are the prices of one region
+type ebsPrices struct {
+	// Volumes holds the prices of every volume type
+	Volumes   map[string]volumePrice `json:"volumes"`
+	Snapshots struct {
+		Standard float64 `json:"standard"`
+		Archive  float64 `json:"archive"`
+	} `json:"snapshots"`
+}
+
+// volumePrice is the monthly price of a volume type per GB, per provisioned IOPS above
+// IncludedIOPS and per MB/s of throughput above IncludedThroughput
+type volumePrice struct {
+	GB   float64 `json:"gb"`
+	IOPS float64 `json:"iops"`
+	// IOPSTiers replace IOPS for volume types that get cheaper the more IOPS are provisioned
+	IOPSTiers          []priceTier `json:"iopsTiers"`
+	IncludedIOPS       int64       `json:"includedIops"`
+	Throughput         float64     `json:"throughput"`
+	IncludedThroughput int64       `json:"includedThroughput"`
+}
+
+// priceTier prices the units up to UpTo, or every unit left when UpTo is 0
+type priceTier struct {
+	UpTo  int64   `json:"upTo"`
+	Price float64 `json:"price"`
+}
+
+// volumeCost is the estimated monthly cost of a volume, split by what it is charged for
+type volumeCost struct {
+	Storage    float64
+	IOPS       float64
+	Throughput float64
+}
+
+func (c volumeCost) total() float64 {
+	return c.Storage + c.IOPS + c.Throughput
+}
+
+func parseEBSPrices(data []byte) (*ebsPriceTable, error) {
+	var table ebsPriceTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, err
+	}
+	if _, ok := table.Regions[pricingFallbackRegion]; !ok {
+		return nil, fmt.Errorf("the prices of %s are missing, they are used for every region without prices", pricingFallbackRegion)
+	}
+	return &table, nil
+}
+
+func mustParseEBSPrices(data []byte) *ebsPriceTable {
+	table, err := parseEBSPrices(data)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in EBS price table: %v", err))
+	}
+	return table
+}
+
+// loadEBSPrices reads a price table in the format of ebs-prices.json for --ebs-prices
+func loadEBSPrices(path string) (*ebsPriceTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read EBS price table: %v", err)
+	}
+	table, err := parseEBSPrices(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EBS price table %s: %v", path, err)
+	}
+	return table, nil
+}
+
+// region returns the prices of region, or the prices of pricingFallbackRegion and false when the
+// table has none for it
+func (t *ebsPriceTable) region(region string) (ebsPrices, bool) {
+	if prices, ok := t.Regions[region]; ok {
+		return prices, true
+	}
+	return t.Regions[pricingFallbackRegion], false
+}
+
+// regionEBSPrices returns the EBS prices of the region of clients, and notes on the result of
+// check when they are estimated from the prices of another region
+func regionEBSPrices(clients *Clients, report *Report, checkID string) ebsPrices {
+	prices, ok := clients.EBSPrices.region(clients.Region)
+	if !ok {
+		report.Note(checkID, "No EBS prices for %s, costs are estimated with the prices of %s", clients.Region, pricingFallbackRegion)
+	}
+	return prices
+}
+
+// tieredCost prices units across tiers
+func tieredCost(units int64, tiers []priceTier) float64 {
+	var cost float64
+	var priced int64
+	for _, tier := range tiers {
+		if units <= priced {
+			break
+		}
+		n := units - priced
+		if tier.UpTo > 0 && tier.UpTo-priced < n {
+			n = tier.UpTo - priced
+		}
+		cost += float64(n) * tier.Price
+		priced += n
+	}
+	return cost
+}
+
+// volumeTypeCost estimates the monthly cost of a volume of volumeType with size GB, provisioned
+// IOPS and throughput in MB/s. It returns false for volume types that are not in the table.
+func (p ebsPrices) volumeTypeCost(volumeType string, size, iops, throughput int64) (volumeCost, bool) {
+	price, ok := p.Volumes[volumeType]
+	if !ok {
+		return volumeCost{}, false
+	}
+	cost := volumeCost{Storage: float64(size) * price.GB}
+	if extra := iops - price.IncludedIOPS; extra > 0 {
+		if len(price.IOPSTiers) > 0 {
+			cost.IOPS = tieredCost(extra, price.IOPSTiers)
+		} else {
+			cost.IOPS = float64(extra) * price.IOPS
+		}
+	}
+	if extra := throughput - price.IncludedThroughput; extra > 0 {
+		cost.Throughput = float64(extra) * price.Throughput
+	}
+	return cost, true
+}
+
+// volumeCost estimates the monthly cost of volume. Volume types that only charge for storage
+// ignore the IOPS DescribeVolumes reports for them, such as the baseline IOPS of gp2.
+func (p ebsPrices) volumeCost(volume *ec2.Volume) (volumeCost, bool) {
+	return p.volumeTypeCost(aws.StringValue(volume.VolumeType), aws.Int64Value(volume.Size), aws.Int64Value(volume.Iops), aws.Int64Value(volume.Throughput))
+}
+
+// snapshotCost estimates the monthly cost of a snapshot in its storage tier. Snapshots are
+// incremental, so the size of the volume is an upper bound of the data stored.
+func (p ebsPrices) snapshotCost(snapshot *ec2.Snapshot) float64 {
+	size := float64(aws.Int64Value(snapshot.VolumeSize))
+	if aws.StringValue(snapshot.StorageTier) == ec2.StorageTierArchive {
+		return size * p.Snapshots.Archive
+	}
+	return size * p.Snapshots.Standard
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestTieredCost(t *testing.T) {
+	tiers := []priceTier{{UpTo: 100, Price: 2}, {UpTo: 300, Price: 1}, {Price: 0.5}}
+	tests := []struct {
+		name  string
+		units int64
+		tiers []priceTier
+		want  float64
+	}{
+		{"no units", 0, tiers, 0},
+		{"negative units", -5, tiers, 0},
+		{"first tier", 40, tiers, 80},
+		{"end of first tier", 100, tiers, 200},
+		{"second tier", 150, tiers, 250},
+		{"end of second tier", 300, tiers, 400},
+		{"last tier", 500, tiers, 500},
+		{"no tiers", 100, nil, 0},
+		{"units beyond the last bounded tier", 150, []priceTier{{UpTo: 100, Price: 1}}, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tieredCost(tt.units, tt.tiers); !almostEqual(got, tt.want) {
+				t.Errorf("tieredCost(%d) = %v, want %v", tt.units, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVolumeTypeCost(t *testing.T) {
+	prices := ebsPrices{Volumes: map[string]volumePrice{
+		"gp2": {GB: 0.1},
+		"gp3": {GB: 0.08, IOPS: 0.005, IncludedIOPS: 3000, Throughput: 0.04, IncludedThroughput: 125},
+		"io1": {GB: 0.125, IOPS: 0.065},
+		"io2": {GB: 0.125, IOPSTiers: []priceTier{{UpTo: 32000, Price: 0.065}, {UpTo: 64000, Price: 0.0455}, {Price: 0.032}}},
+	}}
+	tests := []struct {
+		name             string
+		volumeType       string
+		size, iops, tput int64
+		want             volumeCost
+		ok               bool
+	}{
+		{"storage only", "gp2", 100, 300, 128, volumeCost{Storage: 10}, true},
+		{"included performance", "gp3", 100, 3000, 125, volumeCost{Storage: 8}, true},
+		{"performance above included", "gp3", 100, 4000, 250, volumeCost{Storage: 8, IOPS: 5, Throughput: 5}, true},
+		{"flat IOPS", "io1", 100, 1000, 0, volumeCost{Storage: 12.5, IOPS: 65}, true},
+		{"tiered IOPS", "io2", 100, 40000, 0, volumeCost{Storage: 12.5, IOPS: 32000*0.065 + 8000*0.0455}, true},
+		{"unknown type", "st1", 100, 0, 0, volumeCost{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := prices.volumeTypeCost(tt.volumeType, tt.size, tt.iops, tt.tput)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !almostEqual(got.Storage, tt.want.Storage) || !almostEqual(got.IOPS, tt.want.IOPS) || !almostEqual(got.Throughput, tt.want.Throughput) {
+				t.Errorf("volumeTypeCost = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -8,11 +9,11 @@ import (
 	"github.com/aws/aws-sdk-go/service/rds"
 )
 
-func listRDSInstances(rdsClient *rds.RDS) ([]*rds.DBInstance, error) {
+func listRDSInstances(ctx context.Context, rdsClient *rds.RDS) ([]*rds.DBInstance, error) {
 	input := &rds.DescribeDBInstancesInput{
 		MaxRecords: aws.Int64(100),
 	}
-	result, err := rdsClient.DescribeDBInstances(input)
+	result, err := rdsClient.DescribeDBInstancesWithContext(ctx, input)
 
 	if err != nil {
 		return nil, err
@@ -21,52 +22,46 @@ func listRDSInstances(rdsClient *rds.RDS) ([]*rds.DBInstance, error) {
 	return result.DBInstances, nil
 }
 
-func checkRDSInstanceAttributes(dbInstances []*rds.DBInstance) {
-	fmt.Printf("\n#### Analyzing %d RDS Instances ####\n", len(dbInstances))
+// RDSIssue is a negative finding about one RDS instance
+type RDSIssue struct {
+	DBInstanceIdentifier string `json:"dbInstanceIdentifier"`
+	Issue                string `json:"issue"`
+	// Severity and Category vary by issue, a public instance matters more than a gp2 disk
+	Severity Severity `json:"severity"`
+	Category Category `json:"category"`
+}
+
+func checkRDSInstanceAttributes(dbInstances []*rds.DBInstance) []RDSIssue {
+	var issues []RDSIssue
 	for _, instance := range dbInstances {
-		fmt.Printf("\n--- Instance ID: %s ---\n", *instance.DBInstanceIdentifier)
-		hasNegativeFindings := false
+		id := *instance.DBInstanceIdentifier
 
 		// Publicly Accessible
 		if instance.PubliclyAccessible != nil && *instance.PubliclyAccessible {
-			fmt.Printf("  ❌ Publicly Accessible\n")
-			hasNegativeFindings = true
+			issues = append(issues, RDSIssue{id, "Publicly Accessible", SeverityHigh, CategorySecurity})
 		}
 
 		// Storage Encryption
 		if instance.StorageEncrypted != nil && !*instance.StorageEncrypted {
-			fmt.Printf("  ❌ Encryption Not Enabled\n")
-			hasNegativeFindings = true
+			issues = append(issues, RDSIssue{id, "Encryption Not Enabled", SeverityHigh, CategorySecurity})
 		}
 
 		// Disk Type
 		if instance.StorageType != nil && *instance.StorageType == "gp2" {
-			fmt.Printf("  ⚠️ Using gp2 disk type (Consider upgrading to GP3)\n")
-			hasNegativeFindings = true
+			issues = append(issues, RDSIssue{id, "Using gp2 disk type (Consider upgrading to GP3)", SeverityLow, CategoryCost})
 		}
 
 		// MultiAZ
-		if instance.MultiAZ != nil && *instance.MultiAZ {
-			fmt.Printf("  ⚠️  MultiAZ Enabled\n")
-		} else {
-			fmt.Printf("  ⚠️ MultiAZ Not Enabled\n")
-			hasNegativeFindings = true
+		if instance.MultiAZ == nil || !*instance.MultiAZ {
+			issues = append(issues, RDSIssue{id, "MultiAZ Not Enabled", SeverityMedium, CategoryReliability})
 		}
 
 		// Backup Retention
-		if instance.BackupRetentionPeriod != nil && *instance.BackupRetentionPeriod > 0 {
-			fmt.Printf(" ❓ Backup Retention: %d Days\n", *instance.BackupRetentionPeriod)
-		} else {
-			fmt.Printf("  ❌ Backup Retention: Not Enabled\n")
-			hasNegativeFindings = true
-		}
-
-		if !hasNegativeFindings {
-			fmt.Printf("  ✅ No negative findings\n")
+		if instance.BackupRetentionPeriod == nil || *instance.BackupRetentionPeriod == 0 {
+			issues = append(issues, RDSIssue{id, "Backup Retention: Not Enabled", SeverityHigh, CategoryReliability})
 		}
-
-		fmt.Println("-------------------------------") // Separator for the next instance
 	}
+	return issues
 }
 
 // printStorageUsageBar generates a visual representation of storage usage
@@ -76,3 +71,42 @@ func printStorageUsageBar(percentageUsed int, allocatedStorage int64) {
 	bar := strings.Repeat("█", usedLength) + strings.Repeat("░", barLength-usedLength)
 	fmt.Printf("  Storage Usage: [%s] %d%% of %d GB\n", bar, percentageUsed, allocatedStorage)
 }
+
+var rdsAttributesCheck = &basicCheck{
+	id:          "rds-instance-attributes",
+	service:     "rds",
+	severity:    SeverityHigh,
+	category:    CategorySecurity,
+	description: "RDS instances with negative findings",
+	help:        "Disable public access, enable storage encryption, Multi-AZ and automated backups, and use gp3 storage for RDS instances that hold production data.",
+	arn:         "arn:{partition}:rds:{region}:{account}:db:{id}",
+}
+
+func init() {
+	rdsAttributesCheck.run = runRDSAttributesCheck
+	registerCheck(rdsAttributesCheck)
+}
+
+func runRDSAttributesCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	rdsInstances, err := listRDSInstances(ctx, clients.RDS)
+	if err != nil {
+		return nil, err
+	}
+	issues := checkRDSInstanceAttributes(rdsInstances)
+	instanceIDs := make([]string, 0, len(rdsInstances))
+	for _, instance := range rdsInstances {
+		instanceIDs = append(instanceIDs, aws.StringValue(instance.DBInstanceIdentifier))
+	}
+	report.RecordEvaluated(rdsAttributesCheck.id, instanceIDs)
+	report.Update(func(f *Findings) {
+		f.RDSInstances = RDSInstances{TotalAnalyzed: len(rdsInstances), Issues: issues}
+	})
+	var findings []Finding
+	for _, issue := range issues {
+		finding := rdsAttributesCheck.finding(issue.DBInstanceIdentifier,
+			"Instance ID: %s: %s", issue.DBInstanceIdentifier, issue.Issue).withAttribute("issue", issue.Issue)
+		finding.Severity, finding.Category = issue.Severity, issue.Category
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
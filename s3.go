@@ -1,12 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
-	"os"
+	"sort"
 	"strings"
 	"sync"
-	"text/tabwriter"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -14,8 +14,8 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
-func getAllBucketNames(svc *s3.S3) ([]string, error) {
-	buckets, err := svc.ListBuckets(nil)
+func getAllBucketNames(ctx context.Context, svc *s3.S3) ([]string, error) {
+	buckets, err := svc.ListBucketsWithContext(ctx, &s3.ListBucketsInput{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list buckets: %v", err)
 	}
@@ -24,10 +24,8 @@ func getAllBucketNames(svc *s3.S3) ([]string, error) {
 	for i, bucket := range buckets.Buckets {
 		bucketNames[i] = *bucket.Name
 	}
-	fmt.Printf("\n Found %d buckets\n", len(bucketNames))
 	// If there are more than 100 buckets, randomly select 100 of them
 	if len(bucketNames) > 100 {
-		fmt.Println("More than 100 buckets found, randomly selecting 100 buckets")
 		rand.Seed(time.Now().UnixNano())
 		selectedBucketNames := make([]string, 100)
 		for i := range selectedBucketNames {
@@ -42,12 +40,12 @@ func getAllBucketNames(svc *s3.S3) ([]string, error) {
 	return bucketNames, nil
 }
 
-func getBucketLifecyclePolicy(svc *s3.S3, bucketName string) (bool, error) {
+func getBucketLifecyclePolicy(ctx context.Context, svc *s3.S3, bucketName string) (bool, error) {
 	input := &s3.GetBucketLifecycleConfigurationInput{
 		Bucket: aws.String(bucketName),
 	}
 
-	_, err := svc.GetBucketLifecycleConfiguration(input)
+	_, err := svc.GetBucketLifecycleConfigurationWithContext(ctx, input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NoSuchLifecycleConfiguration" {
 			return false, nil
@@ -59,10 +57,8 @@ func getBucketLifecyclePolicy(svc *s3.S3, bucketName string) (bool, error) {
 	return true, nil
 }
 
-func getPercentageStorageclasses(svc *s3.S3, bucketNames []string) {
-	bucketsWithoutLifecycle := 0
-	skippedBuckets := 0
-
+// forEachBucket calls fn for every bucket, 20 at a time
+func forEachBucket(bucketNames []string, fn func(bucketName string)) {
 	var wg sync.WaitGroup
 	// Semaphore to limit concurrency
 	concurrencyLimit := make(chan struct{}, 20) // Adjust this value based on your rate limit
@@ -73,74 +69,173 @@ func getPercentageStorageclasses(svc *s3.S3, bucketNames []string) {
 			defer wg.Done()
 			concurrencyLimit <- struct{}{}        // Acquire
 			defer func() { <-concurrencyLimit }() // Release
+			fn(bucketName)
+		}(bucketName)
+	}
 
-			input := &s3.ListObjectsV2Input{
-				Bucket:  aws.String(bucketName),
-				MaxKeys: aws.Int64(100),
-			}
-
-			result, err := svc.ListObjectsV2(input)
-			if err != nil {
-				if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "BucketRegionError" {
-					fmt.Printf("Bucket %s is in another region, skipping\n", bucketName)
-					skippedBuckets++
-					return
-				} else {
-					fmt.Printf("Failed to list objects in bucket %s: %v\n", bucketName, err)
-					return
-				}
-			}
-
-			// Calculate the storage class counts
-			storageClassCounts := make(map[string]int)
-			totalObjects := len(result.Contents)
-			for _, object := range result.Contents {
-				storageClass := aws.StringValue(object.StorageClass)
-				storageClassCounts[storageClass]++
-			}
+	// Wait for all goroutines to complete
+	wg.Wait()
+}
 
-			// Create a new tabwriter.Writer
-			writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			// Print the bucket name and storage class percentages
-			fmt.Fprintf(writer, "Bucket: %s\n", bucketName)
-			fmt.Fprintf(writer, "%-20s\t|\t%-10s\n", "StorageClass", "Percentage")
-			fmt.Fprintln(writer, "--------------------\t|\t-----------")
+// getPercentageStorageclasses samples up to 100 objects of every bucket and returns the share of each
+// storage class. Buckets in another region are skipped.
+func getPercentageStorageclasses(ctx context.Context, svc *s3.S3, bucketNames []string) ([]Bucket, error) {
+	var mu sync.Mutex
+	var firstErr error
+	buckets := make([]Bucket, 0, len(bucketNames))
+
+	forEachBucket(bucketNames, func(bucketName string) {
+		input := &s3.ListObjectsV2Input{
+			Bucket:  aws.String(bucketName),
+			MaxKeys: aws.Int64(100),
+		}
 
-			for storageClass, count := range storageClassCounts {
-				percentage := float64(count) / float64(totalObjects) * 100
-				fmt.Fprintf(writer, "%-20s\t|\t%9.2f%%\n", storageClass, percentage)
-			}
-			// Flush the tabwriter to print the formatted output
-			writer.Flush()
-			fmt.Println()
-
-			// Call `getBucketLifecyclePolicy` for each bucket
-			hasLifecycle, err := getBucketLifecyclePolicy(svc, bucketName)
-			if err != nil {
-				fmt.Printf("Failed to get lifecycle policy for bucket %s: %v\n", bucketName, err)
-				skippedBuckets++
+		result, err := svc.ListObjectsV2WithContext(ctx, input)
+		if err != nil {
+			if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "BucketRegionError" {
 				return
 			}
-			if !hasLifecycle {
-				bucketsWithoutLifecycle++
+			mu.Lock()
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to list objects in bucket %s: %v", bucketName, err)
 			}
-		}(bucketName)
+			mu.Unlock()
+			return
+		}
+
+		// Calculate the storage class counts
+		storageClassCounts := make(map[string]int)
+		totalObjects := len(result.Contents)
+		for _, object := range result.Contents {
+			storageClass := aws.StringValue(object.StorageClass)
+			storageClassCounts[storageClass]++
+		}
+		percentages := make(map[string]string)
+		for storageClass, count := range storageClassCounts {
+			percentage := float64(count) / float64(totalObjects) * 100
+			percentages[storageClass] = fmt.Sprintf("%.2f%%", percentage)
+		}
+
+		mu.Lock()
+		buckets = append(buckets, Bucket{Name: bucketName, StorageClassPercentages: percentages})
+		mu.Unlock()
+	})
+
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Name < buckets[j].Name
+	})
+	return buckets, firstErr
+}
+
+// findBucketsWithoutLifecycle returns the buckets that have no lifecycle policy, and how many buckets could
+// be checked. Buckets whose policy cannot be read, usually because they are in another region, are skipped.
+func findBucketsWithoutLifecycle(ctx context.Context, svc *s3.S3, bucketNames []string) ([]string, int) {
+	var mu sync.Mutex
+	var withoutLifecycle []string
+	processedBuckets := 0
+
+	forEachBucket(bucketNames, func(bucketName string) {
+		hasLifecycle, err := getBucketLifecyclePolicy(ctx, svc, bucketName)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			return
+		}
+		processedBuckets++
+		if !hasLifecycle {
+			withoutLifecycle = append(withoutLifecycle, bucketName)
+		}
+	})
+
+	sort.Strings(withoutLifecycle)
+	return withoutLifecycle, processedBuckets
+}
+
+// formatStorageClasses renders storage class percentages as "STANDARD 80.00%, GLACIER 20.00%"
+func formatStorageClasses(percentages map[string]string) string {
+	storageClasses := make([]string, 0, len(percentages))
+	for storageClass := range percentages {
+		storageClasses = append(storageClasses, storageClass)
+	}
+	sort.Strings(storageClasses)
+	parts := make([]string, len(storageClasses))
+	for i, storageClass := range storageClasses {
+		parts[i] = fmt.Sprintf("%s %s", storageClass, percentages[storageClass])
 	}
+	return strings.Join(parts, ", ")
+}
 
-	// Wait for all goroutines to complete
-	wg.Wait()
+var (
+	lifecyclePoliciesCheck = &basicCheck{
+		id:          "s3-lifecycle-policies",
+		service:     "s3",
+		severity:    SeverityLow,
+		category:    CategoryCost,
+		description: "S3 buckets without a lifecycle policy",
+		help:        "Without a lifecycle policy objects stay in their storage class forever. Add rules that move old objects to cheaper classes or expire them.",
+		arn:         "arn:{partition}:s3:::{id}",
+		global:      true,
+	}
+	storageClassesCheck = &basicCheck{
+		id:          "s3-storage-classes",
+		service:     "s3",
+		severity:    SeverityInfo,
+		category:    CategoryCost,
+		description: "S3 storage class distribution",
+		help:        "Objects that are rarely read are cheaper in the infrequent access, Glacier or Intelligent-Tiering storage classes.",
+		arn:         "arn:{partition}:s3:::{id}",
+		global:      true,
+	}
+)
 
-	// Calculate and print the percentage of buckets without a lifecycle policy
-	// considering only the processed buckets (excluding skipped buckets)
-	processedBuckets := len(bucketNames) - skippedBuckets
-	percentageWithoutLifecycle := float64(bucketsWithoutLifecycle) / float64(processedBuckets) * 100
-	bannerText := fmt.Sprintf("Percentage of buckets without a lifecycle policy: %.2f%%", percentageWithoutLifecycle)
-	printBanner(bannerText)
+func init() {
+	lifecyclePoliciesCheck.run = runLifecyclePoliciesCheck
+	storageClassesCheck.run = runStorageClassesCheck
+
+	registerCheck(lifecyclePoliciesCheck)
+	registerCheck(storageClassesCheck)
 }
 
-func printBanner(text string) {
-	border := strings.Repeat("=", len(text)+6)
-	fmt.Printf("%s\n", border)
-	fmt.Printf("== %s ==\n", text)
-	fmt.Printf("%s\n", border)
+func runLifecyclePoliciesCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	bucketNames, err := getAllBucketNames(ctx, clients.S3)
+	if err != nil {
+		return nil, err
+	}
+	withoutLifecycle, processedBuckets := findBucketsWithoutLifecycle(ctx, clients.S3, bucketNames)
+	report.RecordEvaluated(lifecyclePoliciesCheck.id, bucketNames)
+	report.Update(func(f *Findings) {
+		f.S3Buckets.TotalBuckets = len(bucketNames)
+		f.S3Buckets.BucketsWithoutLifecyclePolicy = withoutLifecycle
+		if processedBuckets > 0 {
+			percentageWithoutLifecycle := float64(len(withoutLifecycle)) / float64(processedBuckets) * 100
+			f.S3Buckets.BucketsWithoutLifecyclePolicyPercentage = fmt.Sprintf("%.2f%%", percentageWithoutLifecycle)
+		}
+	})
+	var findings []Finding
+	for _, bucketName := range withoutLifecycle {
+		findings = append(findings, lifecyclePoliciesCheck.finding(bucketName,
+			"Bucket %s has no lifecycle policy", bucketName))
+	}
+	return findings, nil
+}
+
+func runStorageClassesCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	bucketNames, err := getAllBucketNames(ctx, clients.S3)
+	if err != nil {
+		return nil, err
+	}
+	buckets, err := getPercentageStorageclasses(ctx, clients.S3, bucketNames)
+	report.Update(func(f *Findings) {
+		f.S3Buckets.TotalBuckets = len(bucketNames)
+		f.S3Buckets.Buckets = buckets
+	})
+	var findings []Finding
+	for _, bucket := range buckets {
+		if len(bucket.StorageClassPercentages) == 0 {
+			continue
+		}
+		findings = append(findings, storageClassesCheck.finding(bucket.Name,
+			"Bucket %s: %s", bucket.Name, formatStorageClasses(bucket.StorageClassPercentages)))
+	}
+	return findings, err
 }
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// The subset of SARIF 2.1.0 avm writes. Every check is a rule and every finding a result whose
+// location is the ARN of the resource.
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	avmURL       = "https://github.com/bit-cloner/aws-vitals-monitor"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	FullDescription      sarifMessage       `json:"fullDescription"`
+	Help                 sarifMessage       `json:"help"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+	Properties           sarifProperties    `json:"properties"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifProperties struct {
+	Tags []string `json:"tags,omitempty"`
+	// SecuritySeverity is the 0-10 score code scanning uses to rank security alerts
+	SecuritySeverity string `json:"security-severity,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID     string          `json:"ruleId"`
+	RuleIndex  int             `json:"ruleIndex"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations"`
+	Properties sarifProperties `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name,omitempty"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifLevel maps a severity to one of the SARIF result levels
+func sarifLevel(severity Severity) string {
+	switch severity {
+	case SeverityCritical, SeverityHigh:
+		return "error"
+	case SeverityMedium:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+func sarifSecuritySeverity(severity Severity) string {
+	switch severity {
+	case SeverityCritical:
+		return "9.5"
+	case SeverityHigh:
+		return "8.0"
+	case SeverityMedium:
+		return "5.5"
+	case SeverityLow:
+		return "3.0"
+	default:
+		return "0.0"
+	}
+}
+
+// sarifLocationOf places a finding at the ARN of its resource. Findings that are not about a
+// single resource, such as summaries, are placed at the account and region they were found in.
+func sarifLocationOf(f Finding) sarifLocation {
+	uri := f.ResourceARN
+	if uri == "" {
+		parts := []string{f.AccountID, f.Region, f.Service}
+		if f.ResourceID != "" {
+			parts = append(parts, f.ResourceID)
+		}
+		uri = "aws://" + strings.Join(parts, "/")
+	}
+	return sarifLocation{
+		PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: uri}},
+		LogicalLocations: []sarifLogicalLocation{{Name: f.ResourceID, FullyQualifiedName: uri, Kind: "resource"}},
+	}
+}
+
+func renderSARIF(w io.Writer, report ScanReport) error {
+	driver := sarifDriver{Name: "avm", InformationURI: avmURL, Rules: []sarifRule{}}
+	ruleIndex := make(map[string]int)
+	addRule := func(c Check) {
+		if _, ok := ruleIndex[c.ID()]; ok {
+			return
+		}
+		ruleIndex[c.ID()] = len(driver.Rules)
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:                   c.ID(),
+			Name:                 c.ID(),
+			ShortDescription:     sarifMessage{Text: c.Description()},
+			FullDescription:      sarifMessage{Text: c.Description()},
+			Help:                 sarifMessage{Text: checkHelp(c)},
+			DefaultConfiguration: sarifConfiguration{Level: sarifLevel(c.Severity())},
+			Properties: sarifProperties{
+				Tags:             []string{string(c.Category()), c.Service()},
+				SecuritySeverity: sarifSecuritySeverity(c.Severity()),
+			},
+		})
+	}
+	// Every check that ran is a rule, including the ones with nothing to report
+	for _, account := range report.Accounts {
+		for _, region := range account.Regions {
+			for _, id := range region.ChecksRun {
+				if c := lookupCheck(id); c != nil {
+					addRule(c)
+				}
+			}
+		}
+	}
+
+	results := []sarifResult{}
+	for _, f := range report.findings() {
+		index, ok := ruleIndex[f.CheckID]
+		if !ok {
+			continue
+		}
+		results = append(results, sarifResult{
+			RuleID:    f.CheckID,
+			RuleIndex: index,
+			Level:     sarifLevel(f.Severity),
+			Message:   sarifMessage{Text: f.Message},
+			Locations: []sarifLocation{sarifLocationOf(f)},
+			Properties: sarifProperties{
+				Tags:             []string{string(f.Category), f.Service, f.AccountID, f.Region},
+				SecuritySeverity: sarifSecuritySeverity(f.Severity),
+			},
+		})
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+	jsonData, err := json.MarshalIndent(log, "", "    ")
+	if err != nil {
+		return fmt.Errorf("error with parsing sarif data: %v", err)
+	}
+	_, err = fmt.Fprintln(w, string(jsonData))
+	return err
+}
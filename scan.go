@@ -0,0 +1,386 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// globalRegion is the region reported for checks of global services, which run only once
+const globalRegion = "global"
+
+// scanTarget is one batch of checks run with one set of clients
+type scanTarget struct {
+	region  string
+	clients *Clients
+	checks  []Check
+}
+
+// runScan runs the checks selected in cfg against every selected account and region and
+// renders the report
+func runScan(ctx context.Context, cfg *scanConfig) error {
+	if cfg.Notify.OnlyNew && cfg.Notify.enabled() && cfg.History == "" {
+		return errors.New("--notify-only-new compares with the last run in the history, it cannot be used with --no-history")
+	}
+	out, progress, closeOut, err := openOutput(cfg.OutFile, cfg.Output == "text")
+	if err != nil {
+		return err
+	}
+	defer closeOut()
+
+	report, err := scan(ctx, cfg, progress)
+	if err != nil {
+		return err
+	}
+	if err := renderers[cfg.Output](out, report); err != nil {
+		return err
+	}
+	if err := closeOut(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", cfg.OutFile, err)
+	}
+	n := notification{Report: report}
+	if cfg.Notify.enabled() && cfg.History != "" {
+		n.New = newSinceLastRun(cfg, report)
+	}
+	if cfg.History != "" {
+		n.Run = saveToHistory(cfg, report, progress)
+	}
+	if cfg.Notify.enabled() {
+		for _, err := range notifyAll(ctx, newNotifiers(cfg.Notify), n) {
+			fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+		}
+	}
+	if cfg.Upload.enabled() {
+		if err := uploadScan(ctx, cfg, report, time.Now(), progress); err != nil {
+			return err
+		}
+	}
+	// An incomplete report must not pass a CI gate, whatever its findings
+	if err := incompleteScan(report); err != nil {
+		return err
+	}
+	if cfg.FailOn != "" {
+		if count := report.countAtLeast(cfg.FailOn); count > 0 {
+			return &failOnError{threshold: cfg.FailOn, count: count}
+		}
+	}
+	return nil
+}
+
+// saveToHistory keeps the report for 'avm diff'. The scan itself succeeded, so a history that
+// cannot be written is only warned about.
+func saveToHistory(cfg *scanConfig, report ScanReport, progress io.Writer) historyRun {
+	run, err := saveRun(cfg, triggerScan, report)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Scan not saved to the history: %v\n", err)
+		return historyRun{}
+	}
+	fmt.Fprintf(progress, "\n📦 Saved as run %d, see 'avm diff' for what changed since the previous run\n", run.ID)
+	return run
+}
+
+// uploadScan uploads the reports of a scan with the credentials in use, see uploadReports
+func uploadScan(ctx context.Context, cfg *scanConfig, report ScanReport, t time.Time, progress io.Writer) error {
+	clients, err := newClientFactory(cfg).clients(cfg.Region, nil)
+	if err != nil {
+		return err
+	}
+	return uploadReports(ctx, clients, cfg.Upload, report, t, progress)
+}
+
+// newSinceLastRun returns the findings of report the last run of the same configuration in the
+// history did not have, none when there is no such run. Only the checks that ran without error in
+// both runs are compared, see diffReports.
+func newSinceLastRun(cfg *scanConfig, report ScanReport) []Finding {
+	history, err := openHistory(cfg.History)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Cannot read the history, no finding is taken as new: %v\n", err)
+		return nil
+	}
+	defer history.Close()
+	_, last, ok, err := history.LastOf(historyConfig(cfg))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Cannot read the last run of the history, no finding is taken as new: %v\n", err)
+	}
+	if !ok {
+		return nil
+	}
+	return diffReports(last, report).New
+}
+
+// scan runs the checks selected in cfg against every selected account and region, printing the
+// findings to progress as they come. Once ctx is cancelled the scan stops with its error rather
+// than returning a report of checks that were cut short.
+func scan(ctx context.Context, cfg *scanConfig, progress io.Writer) (ScanReport, error) {
+	home, err := newClientFactory(cfg).clients(cfg.Region, nil)
+	if err != nil {
+		return ScanReport{}, err
+	}
+	callerInfo, err := getAccountInfo(ctx, home.IAM, home.STS)
+	if err != nil {
+		return ScanReport{}, err
+	}
+	accounts, err := resolveAccounts(ctx, cfg, home, callerInfo)
+	if err != nil {
+		return ScanReport{}, err
+	}
+
+	// Accounts are scanned one after the other; the regions of each account are scanned concurrently
+	reports := make([]AccountReport, 0, len(accounts))
+	for _, account := range accounts {
+		report, err := scanOrganizationAccount(ctx, cfg, home, callerInfo, account, progress)
+		if ctx.Err() != nil {
+			return ScanReport{}, fmt.Errorf("scan interrupted: %v", ctx.Err())
+		}
+		if err != nil {
+			if len(accounts) == 1 {
+				return ScanReport{}, err
+			}
+			// One account the role cannot be assumed in should not stop the scan of the others
+			fmt.Fprintf(progress, "\n❌ Skipping account %s: %v\n", account.ID, err)
+			report = AccountReport{AccountId: account.ID, AccountAlias: account.Name, Error: err.Error()}
+		}
+		reports = append(reports, report)
+	}
+	return newScanReport(reports), nil
+}
+
+// scanProgress counts the checks of a scan as they finish, for 'avm serve' to report. The checks
+// of an account are planned once its regions are known, so the number planned grows as the
+// accounts of an organisation are scanned. The methods of a nil scanProgress do nothing.
+type scanProgress struct {
+	mu      sync.Mutex
+	planned int
+	checked int
+}
+
+func (p *scanProgress) plan(checks int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.planned += checks
+}
+
+func (p *scanProgress) done() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.checked++
+}
+
+// counts returns how many checks are done and how many are planned so far
+func (p *scanProgress) counts() (checked, planned int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.checked, p.planned
+}
+
+// failOnError is returned by runScan when findings at or above the --fail-on severity were
+// reported, so that main can exit with exitFindings rather than the exit code used for errors
+type failOnError struct {
+	threshold Severity
+	count     int
+}
+
+func (e *failOnError) Error() string {
+	return fmt.Sprintf("%d findings of severity %s or above", e.count, e.threshold)
+}
+
+// incompleteScanError is returned by runScan when checks failed to run or accounts could not be
+// scanned, so that main exits with exitError although the report was written
+type incompleteScanError struct {
+	checks   int
+	accounts int
+}
+
+func (e *incompleteScanError) Error() string {
+	return fmt.Sprintf("the report is incomplete: %d checks failed to run and %d accounts could not be scanned, see the errors in the report", e.checks, e.accounts)
+}
+
+// incompleteScan returns an *incompleteScanError when checks of report failed to run or accounts
+// could not be scanned
+func incompleteScan(report ScanReport) error {
+	if checks, accounts := report.failedChecks(), report.Summary.FailedAccounts; checks > 0 || accounts > 0 {
+		return &incompleteScanError{checks: checks, accounts: accounts}
+	}
+	return nil
+}
+
+// openOutput returns where the report and the progress of the scan are written to outFile, stdout
+// when empty. Findings are printed as checks finish; unless the report is text they go to stderr,
+// so stdout only carries the rendered report. With --out the text output goes both to the
+// terminal and the file. close may be called more than once.
+func openOutput(outFile string, text bool) (out, progress io.Writer, close func() error, err error) {
+	if outFile == "" {
+		if text {
+			return os.Stdout, os.Stdout, func() error { return nil }, nil
+		}
+		return os.Stdout, os.Stderr, func() error { return nil }, nil
+	}
+	file, err := os.Create(outFile)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to create %s: %v", outFile, err)
+	}
+	var once sync.Once
+	var closeErr error
+	close = func() error {
+		once.Do(func() { closeErr = file.Close() })
+		return closeErr
+	}
+	if text {
+		out = io.MultiWriter(os.Stdout, file)
+		return out, out, close, nil
+	}
+	return file, os.Stdout, close, nil
+}
+
+// resolveAccounts returns the accounts selected with --accounts, or the account of the
+// credentials in use when there is none
+func resolveAccounts(ctx context.Context, cfg *scanConfig, home *Clients, callerInfo AccountInformation) ([]organizationAccount, error) {
+	if len(cfg.Accounts) == 0 {
+		return []organizationAccount{{ID: callerInfo.AccountId, Name: callerInfo.AccountAlias}}, nil
+	}
+	if cfg.Accounts[0] == organizationAccounts {
+		return listOrganizationAccounts(ctx, home.Organizations)
+	}
+	members := make(map[string]organizationAccount)
+	if cfg.organizationScope {
+		list, err := listOrganizationAccounts(ctx, home.Organizations)
+		if err != nil {
+			return nil, err
+		}
+		for _, account := range list {
+			members[account.ID] = account
+		}
+	}
+	accounts := make([]organizationAccount, 0, len(cfg.Accounts))
+	for _, id := range cfg.Accounts {
+		account := organizationAccount{ID: id}
+		if cfg.organizationScope {
+			var ok bool
+			if account, ok = members[id]; !ok {
+				return nil, fmt.Errorf("account %s is not an active account of the organisation", id)
+			}
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+// scanOrganizationAccount assumes the configured role in account, unless it is the account of
+// the credentials in use, and scans every selected region of it
+func scanOrganizationAccount(ctx context.Context, cfg *scanConfig, home *Clients, callerInfo AccountInformation, account organizationAccount, progress io.Writer) (AccountReport, error) {
+	clients, accountInfo := home, callerInfo
+	if account.ID != callerInfo.AccountId {
+		var err error
+		clients, err = home.withCredentials(assumeRoleCredentials(home.Session, account.ID, cfg))
+		if err != nil {
+			return AccountReport{}, err
+		}
+		if accountInfo, err = getAccountInfo(ctx, clients.IAM, clients.STS); err != nil {
+			return AccountReport{}, err
+		}
+		if accountInfo.AccountAlias == "N/A" && account.Name != "" {
+			accountInfo.AccountAlias = account.Name
+		}
+	}
+
+	// Opt-in regions can be enabled in some accounts of an organisation and not in others
+	regions := cfg.Regions
+	if len(regions) == 1 && regions[0] == allRegions {
+		var err error
+		if regions, err = discoverRegions(ctx, clients.EC2); err != nil {
+			return AccountReport{}, err
+		}
+	}
+	printAccountInfo(progress, accountInfo, regions)
+	report, err := scanAccount(ctx, cfg, clients, accountInfo, regions, progress)
+	if err != nil || !cfg.SecurityHub || ctx.Err() != nil {
+		return report, err
+	}
+	// Findings are imported with the credentials of the account they belong to, as Security Hub
+	// only takes findings of other accounts from integrated products
+	if err := exportToSecurityHub(ctx, clients, report, progress); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+	}
+	return report, nil
+}
+
+// scanAccount runs the regional checks in every region and the global checks once, using the
+// home region clients. Regions are scanned concurrently; their output is printed region by region.
+func scanAccount(ctx context.Context, cfg *scanConfig, home *Clients, accountInfo AccountInformation, regions []string, progress io.Writer) (AccountReport, error) {
+	var regional, global []Check
+	for _, c := range cfg.Checks {
+		if isGlobal(c) {
+			global = append(global, c)
+		} else {
+			regional = append(regional, c)
+		}
+	}
+
+	var targets []scanTarget
+	if len(regional) > 0 {
+		for _, region := range regions {
+			clients, err := home.forRegion(region)
+			if err != nil {
+				return AccountReport{}, err
+			}
+			targets = append(targets, scanTarget{region: region, clients: clients, checks: regional})
+		}
+	}
+	if len(global) > 0 {
+		targets = append(targets, scanTarget{region: globalRegion, clients: home, checks: global})
+	}
+
+	for _, target := range targets {
+		cfg.progress.plan(len(target.checks))
+	}
+	reports := make([]*Report, len(targets))
+	done := make([]chan struct{}, len(targets))
+	// The first target prints straight away, the others are held back until it is their turn
+	outputs := make([]io.Writer, len(targets))
+	buffers := make([]bytes.Buffer, len(targets))
+	for i := range targets {
+		done[i] = make(chan struct{})
+		reports[i] = newReport(accountInfo, targets[i].region, targets[i].checks, cfg.Suppressions, cfg.Baseline)
+		outputs[i] = &buffers[i]
+		if len(targets) > 1 {
+			fmt.Fprintf(outputs[i], "\n======== %s (%s) ========\n", targets[i].region, reports[i].Snapshot().AccountInformation.RegionName)
+		}
+	}
+	if len(targets) > 0 {
+		progress.Write(buffers[0].Bytes())
+		outputs[0] = progress
+	}
+
+	// Every region starts at once; --concurrency bounds the checks running across all of them
+	slots := make(chan struct{}, cfg.Concurrency)
+	for i, target := range targets {
+		go func(i int, target scanTarget) {
+			defer close(done[i])
+			runChecks(ctx, target.clients, reports[i], target.checks, slots, func(result checkResult) {
+				printCheckResult(outputs[i], result)
+				cfg.progress.done()
+			})
+		}(i, target)
+	}
+
+	results := make([]MasterStructure, len(targets))
+	for i := range targets {
+		<-done[i]
+		if i > 0 {
+			progress.Write(buffers[i].Bytes())
+		}
+		results[i] = reports[i].Snapshot()
+	}
+	return newAccountReport(accountInfo, results), nil
+}
@@ -1,30 +1,21 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
 )
 
-// a function that accepts region as input and returns a list of security groups
-func getSecurityGroups(region string) ([]*ec2.SecurityGroup, error) {
-	// Create a new session
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(region)},
-	)
-	if err != nil {
-		return nil, err
-	}
-	// Create a new EC2 client
-	svc := ec2.New(sess)
-
+// a function that accepts an EC2 client as input and returns a list of security groups
+func getSecurityGroups(ctx context.Context, svc *ec2.EC2) ([]*ec2.SecurityGroup, error) {
 	// Iterate over each page of results, adding the security groups to the result slice
 	var groups []*ec2.SecurityGroup
 	// Use the DescribeSecurityGroupsPages method to paginate results
-	err = svc.DescribeSecurityGroupsPages(&ec2.DescribeSecurityGroupsInput{
+	err := svc.DescribeSecurityGroupsPagesWithContext(ctx, &ec2.DescribeSecurityGroupsInput{
 		MaxResults: aws.Int64(100),
 	}, func(page *ec2.DescribeSecurityGroupsOutput, lastPage bool) bool {
 		for _, group := range page.SecurityGroups {
@@ -38,7 +29,7 @@ func getSecurityGroups(region string) ([]*ec2.SecurityGroup, error) {
 		// Check if there are no security groups found in the region
 		if aerr, ok := err.(awserr.Error); ok {
 			if aerr.Code() == "InvalidGroup.NotFound" {
-				return nil, fmt.Errorf("no security groups found in region %s", region)
+				return nil, fmt.Errorf("no security groups found in region %s", aws.StringValue(svc.Config.Region))
 			}
 		}
 		return nil, err
@@ -47,65 +38,50 @@ func getSecurityGroups(region string) ([]*ec2.SecurityGroup, error) {
 	return groups, nil
 }
 
-// a function that accepts groups and region as input and checks if any of the security group rules have a range of ports defined
-func checkSecurityGroupHasPortRange(groups []*ec2.SecurityGroup) bool {
-	var found bool
+// a function that accepts groups as input and returns the security group rules that have a range of ports defined
+func checkSecurityGroupHasPortRange(groups []*ec2.SecurityGroup) []OpenPortIssue {
+	var issues []OpenPortIssue
 	// loop over security groups
 	for _, group := range groups {
 		// loop over security group rules
-
 		for _, ipPermission := range group.IpPermissions {
 			if ipPermission.FromPort != nil && ipPermission.ToPort != nil && *ipPermission.FromPort != *ipPermission.ToPort {
-				fmt.Println("\n\nA security group has a range of ports defined. Please investigate security group: ❌", *group.GroupId)
-				fmt.Println("The range of ports is: ", *ipPermission.FromPort, " to ", *ipPermission.ToPort)
-				found = true
+				issues = append(issues, OpenPortIssue{
+					SecurityGroupId: *group.GroupId,
+					PortRange:       fmt.Sprintf("%d-%d", *ipPermission.FromPort, *ipPermission.ToPort),
+				})
 			}
 		}
-
-	}
-	if found {
-		return true
 	}
-	return false
+	return issues
 }
 
-// Function to check SG has broad private CIDR range as source
-func CheckSecurityGroupHasBroadPrivateCidrRange(groups []*ec2.SecurityGroup) bool {
-	var found bool
+// Function to find SG rules that have a broad private CIDR range as source
+func CheckSecurityGroupHasBroadPrivateCidrRange(groups []*ec2.SecurityGroup) []BroadPrivateCidrRule {
+	var rules []BroadPrivateCidrRule
 	// loop over security groups
 	for _, group := range groups {
 		// loop over security group rules
 		for _, ipPermission := range group.IpPermissions {
 			for _, ipRange := range ipPermission.IpRanges {
+				if ipRange.CidrIp == nil {
+					continue
+				}
 				if *ipRange.CidrIp == "10.0.0.0/8" || *ipRange.CidrIp == "172.16.0.0/12" || *ipRange.CidrIp == "192.168.0.0/16" {
-					fmt.Println("\nA security group has a broad private CIDR range as source. Please investigate security group: ❌", *group.GroupId)
-					fmt.Println("The CIDR range is: ", *ipRange.CidrIp)
-					found = true
+					rules = append(rules, BroadPrivateCidrRule{
+						SecurityGroupId: *group.GroupId,
+						Cidr:            *ipRange.CidrIp,
+					})
 				}
 			}
 		}
 	}
-	if found {
-		return true
-	}
-	return false
+	return rules
 }
 
 // Function to get Ec2 instances that are using default security group
 
-func GetDefaultSecurityGroupInstances(region string) []*ec2.Instance {
-	// Initialize a new AWS session
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(region), // Replace with the AWS region you are using
-	})
-
-	if err != nil {
-		panic(err)
-	}
-
-	// Create a new EC2 client
-	ec2Svc := ec2.New(sess)
-
+func GetDefaultSecurityGroupInstances(ctx context.Context, ec2Svc *ec2.EC2) ([]*ec2.Instance, error) {
 	// Initialize input parameters for DescribeInstances API call
 	input := &ec2.DescribeInstancesInput{
 		Filters: []*ec2.Filter{
@@ -122,7 +98,7 @@ func GetDefaultSecurityGroupInstances(region string) []*ec2.Instance {
 	var result []*ec2.Instance
 
 	// Paginate through the DescribeInstances results
-	err = ec2Svc.DescribeInstancesPages(input,
+	err := ec2Svc.DescribeInstancesPagesWithContext(ctx, input,
 		func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
 			// Append each instance to the result variable
 			for _, reservation := range page.Reservations {
@@ -135,16 +111,16 @@ func GetDefaultSecurityGroupInstances(region string) []*ec2.Instance {
 		})
 
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	// Return the list of instances
-	return result
+	return result, nil
 }
 
-// function to check if any SG has inbound rules that are open to all IPs
-func CheckSecurityGroupHasOpenInboundRules(groups []*ec2.SecurityGroup) bool {
-	var found bool
+// function to find SG inbound rules that are open to all IPs
+func CheckSecurityGroupHasOpenInboundRules(groups []*ec2.SecurityGroup) []ExcessivelyOpenInboundRule {
+	var rules []ExcessivelyOpenInboundRule
 	// loop over security groups
 	for _, group := range groups {
 		// loop over security group rules
@@ -153,12 +129,161 @@ func CheckSecurityGroupHasOpenInboundRules(groups []*ec2.SecurityGroup) bool {
 				// Check for nil values before dereferencing pointers
 				if ipRange.CidrIp != nil && ipPermission.FromPort != nil && group.GroupId != nil {
 					if *ipRange.CidrIp == "0.0.0.0/0" || *ipRange.CidrIp == "::/0" {
-						fmt.Println("\nA security group has an excessively open inbound rule on port", *ipPermission.FromPort, ". Please investigate security group: ❌", *group.GroupId)
-						found = true
+						rules = append(rules, ExcessivelyOpenInboundRule{
+							Port:            int(*ipPermission.FromPort),
+							SecurityGroupId: *group.GroupId,
+						})
 					}
 				}
 			}
 		}
 	}
-	return found
+	return rules
+}
+
+var (
+	portRangeCheck = &basicCheck{
+		id:          "sg-port-range",
+		service:     "vpc",
+		severity:    SeverityMedium,
+		category:    CategorySecurity,
+		description: "Security groups with a range of ports open",
+		help:        "Rules that open a range of ports expose more services than needed. Replace them with rules for the individual ports in use.",
+		arn:         "arn:{partition}:ec2:{region}:{account}:security-group/{id}",
+	}
+	defaultSecurityGroupCheck = &basicCheck{
+		id:          "sg-default-in-use",
+		service:     "vpc",
+		severity:    SeverityMedium,
+		category:    CategorySecurity,
+		description: "Instances using the default security group",
+		help:        "The default security group is shared by everything that is not given a group of its own. Attach a dedicated security group to the instance.",
+		arn:         "arn:{partition}:ec2:{region}:{account}:instance/{id}",
+	}
+	broadPrivateCidrCheck = &basicCheck{
+		id:          "sg-broad-private-cidr",
+		service:     "vpc",
+		severity:    SeverityLow,
+		category:    CategorySecurity,
+		description: "Security groups with a broad private CIDR range as source",
+		help:        "A whole private range as source allows every network using it. Narrow the source to the subnets or security groups that need access.",
+		arn:         "arn:{partition}:ec2:{region}:{account}:security-group/{id}",
+	}
+	openInboundCheck = &basicCheck{
+		id:          "sg-open-to-world",
+		service:     "vpc",
+		severity:    SeverityHigh,
+		category:    CategorySecurity,
+		description: "Security groups open to all sources",
+		help:        "Rules with 0.0.0.0/0 or ::/0 as source allow the whole internet. Restrict the source to known addresses, or put the service behind a load balancer or VPN.",
+		arn:         "arn:{partition}:ec2:{region}:{account}:security-group/{id}",
+	}
+)
+
+func init() {
+	portRangeCheck.run = runPortRangeCheck
+	defaultSecurityGroupCheck.run = runDefaultSecurityGroupCheck
+	broadPrivateCidrCheck.run = runBroadPrivateCidrCheck
+	openInboundCheck.run = runOpenInboundCheck
+
+	registerCheck(portRangeCheck)
+	registerCheck(defaultSecurityGroupCheck)
+	registerCheck(broadPrivateCidrCheck)
+	registerCheck(openInboundCheck)
+}
+
+func securityGroupIDs(groups []*ec2.SecurityGroup) []string {
+	ids := make([]string, 0, len(groups))
+	for _, group := range groups {
+		ids = append(ids, aws.StringValue(group.GroupId))
+	}
+	return ids
+}
+
+// securityGroupTags maps the ID of every group to its tags
+func securityGroupTags(groups []*ec2.SecurityGroup) map[string]map[string]string {
+	tags := make(map[string]map[string]string, len(groups))
+	for _, group := range groups {
+		tags[aws.StringValue(group.GroupId)] = ec2Tags(group.Tags)
+	}
+	return tags
+}
+
+func runPortRangeCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	groups, err := getSecurityGroups(ctx, clients.EC2)
+	if err != nil {
+		return nil, err
+	}
+	issues := checkSecurityGroupHasPortRange(groups)
+	tags := securityGroupTags(groups)
+	report.RecordEvaluated(portRangeCheck.id, securityGroupIDs(groups))
+	var findings []Finding
+	for _, issue := range issues {
+		findings = append(findings, portRangeCheck.finding(issue.SecurityGroupId,
+			"Security group %s has a range of ports defined: %s", issue.SecurityGroupId, issue.PortRange).withTags(tags[issue.SecurityGroupId]).withAttribute("portRange", issue.PortRange))
+	}
+	report.Update(func(f *Findings) {
+		f.SecurityGroups.TotalAnalyzed = len(groups)
+		f.SecurityGroups.OpenPortIssues = issues
+	})
+	return findings, nil
+}
+
+func runDefaultSecurityGroupCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	// get ec2 instances with default security groups
+	instances, err := GetDefaultSecurityGroupInstances(ctx, clients.EC2)
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	var instanceIds []string
+	for _, instance := range instances {
+		instanceIds = append(instanceIds, *instance.InstanceId)
+		findings = append(findings, defaultSecurityGroupCheck.finding(*instance.InstanceId,
+			"Instance %s is using the default security group", *instance.InstanceId).withTags(ec2Tags(instance.Tags)))
+	}
+	report.Update(func(f *Findings) {
+		f.SecurityGroups.DefaultSecurityGroupInstances = instanceIds
+	})
+	return findings, nil
+}
+
+func runBroadPrivateCidrCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	groups, err := getSecurityGroups(ctx, clients.EC2)
+	if err != nil {
+		return nil, err
+	}
+	rules := CheckSecurityGroupHasBroadPrivateCidrRange(groups)
+	tags := securityGroupTags(groups)
+	report.RecordEvaluated(broadPrivateCidrCheck.id, securityGroupIDs(groups))
+	var findings []Finding
+	for _, rule := range rules {
+		findings = append(findings, broadPrivateCidrCheck.finding(rule.SecurityGroupId,
+			"Security group %s has a broad private CIDR range as source: %s", rule.SecurityGroupId, rule.Cidr).withTags(tags[rule.SecurityGroupId]).withAttribute("cidr", rule.Cidr))
+	}
+	report.Update(func(f *Findings) {
+		f.SecurityGroups.TotalAnalyzed = len(groups)
+		f.SecurityGroups.BroadPrivateCidrRules = rules
+	})
+	return findings, nil
+}
+
+func runOpenInboundCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	groups, err := getSecurityGroups(ctx, clients.EC2)
+	if err != nil {
+		return nil, err
+	}
+	rules := CheckSecurityGroupHasOpenInboundRules(groups)
+	tags := securityGroupTags(groups)
+	report.RecordEvaluated(openInboundCheck.id, securityGroupIDs(groups))
+	var findings []Finding
+	for _, rule := range rules {
+		findings = append(findings, openInboundCheck.finding(rule.SecurityGroupId,
+			"Security group %s has an excessively open inbound rule on port %d", rule.SecurityGroupId, rule.Port).withTags(tags[rule.SecurityGroupId]).withAttribute("port", strconv.Itoa(rule.Port)))
+	}
+	report.Update(func(f *Findings) {
+		f.SecurityGroups.TotalAnalyzed = len(groups)
+		f.SecurityGroups.ExcessivelyOpenInboundRules = rules
+	})
+	return findings, nil
 }
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/securityhub"
+)
+
+const (
+	// asffSchemaVersion is the version of the AWS Security Finding Format findings are sent in
+	asffSchemaVersion = "2018-10-08"
+	// securityHubBatchSize is the most findings BatchImportFindings takes in one call
+	securityHubBatchSize = 100
+)
+
+// asffSeverities maps avm severities to the severity labels of Security Hub
+var asffSeverities = map[Severity]string{
+	SeverityCritical: securityhub.SeverityLabelCritical,
+	SeverityHigh:     securityhub.SeverityLabelHigh,
+	SeverityMedium:   securityhub.SeverityLabelMedium,
+	SeverityLow:      securityhub.SeverityLabelLow,
+	SeverityInfo:     securityhub.SeverityLabelInformational,
+}
+
+// asffResourceTypes maps the service and resource type of an ARN to the resource type of Security
+// Hub. Resources that Security Hub has no type for are sent as Other.
+var asffResourceTypes = map[string]string{
+	"ec2:elastic-ip":     "AwsEc2Eip",
+	"ec2:instance":       "AwsEc2Instance",
+	"ec2:security-group": "AwsEc2SecurityGroup",
+	"ec2:subnet":         "AwsEc2Subnet",
+	"ec2:volume":         "AwsEc2Volume",
+	"ecr:repository":     "AwsEcrRepository",
+	"rds:db":             "AwsRdsDbInstance",
+	"s3:":                "AwsS3Bucket",
+	"lambda:function":    "AwsLambdaFunction",
+	"dynamodb:table":     "AwsDynamoDbTable",
+	"iam:user":           "AwsIamUser",
+	"iam:role":           "AwsIamRole",
+}
+
+// securityHubProductARN is the product findings of account are imported as: the default product
+// Security Hub has for findings of the account itself
+func securityHubProductARN(region, account string) string {
+	return fmt.Sprintf("arn:%s:securityhub:%s:%s:product/%s/default", partition(region), region, account, account)
+}
+
+// asffID identifies a finding in Security Hub. It is stable across scans, so that a finding
+// found again updates the one imported before.
+func asffID(f Finding, region string) string {
+	return fmt.Sprintf("avm/%s/%s/%s", region, f.CheckID, f.Fingerprint)
+}
+
+// asffResource describes the resource of a finding, or the account for findings about no single
+// resource
+func asffResource(f Finding, region string) *securityhub.Resource {
+	resource := &securityhub.Resource{
+		Type:      aws.String("Other"),
+		Partition: aws.String(partition(region)),
+		Region:    aws.String(region),
+	}
+	if f.ResourceARN != "" {
+		resource.Id = aws.String(f.ResourceARN)
+		if parsed, err := arn.Parse(f.ResourceARN); err == nil {
+			kind := parsed.Resource
+			if i := strings.IndexAny(kind, "/:"); i >= 0 {
+				kind = kind[:i]
+			} else if parsed.Service == "s3" {
+				kind = ""
+			}
+			if t, ok := asffResourceTypes[parsed.Service+":"+kind]; ok {
+				resource.Type = aws.String(t)
+			}
+		}
+	} else if f.ResourceID != "" {
+		resource.Id = aws.String(f.ResourceID)
+	} else {
+		resource.Type = aws.String("AwsAccount")
+		resource.Id = aws.String("AWS::::Account:" + f.AccountID)
+	}
+	if len(f.Tags) > 0 {
+		resource.Tags = aws.StringMap(f.Tags)
+	}
+	return resource
+}
+
+// truncateText cuts text to at most max characters, as Security Hub rejects longer fields
+func truncateText(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:max-1]) + "…"
+}
+
+// asffFinding converts a finding to the AWS Security Finding Format. region is the region it is
+// imported in, the home region for findings of global checks. createdAt is kept from an earlier
+// import of the finding.
+func asffFinding(f Finding, region string, createdAt, now string) *securityhub.AwsSecurityFinding {
+	title, help := f.CheckID, ""
+	if c := lookupCheck(f.CheckID); c != nil {
+		title, help = c.Description(), checkHelp(c)
+	}
+	findingType := "Software and Configuration Checks/AWS Security Best Practices"
+	if f.Category != CategorySecurity {
+		findingType = "Software and Configuration Checks/AWS Vitals Monitor/" + string(f.Category)
+	}
+	fields := map[string]string{
+		"avm/fingerprint": f.Fingerprint,
+		"avm/service":     f.Service,
+		"avm/region":      f.Region,
+	}
+	if f.MonthlyCost > 0 {
+		fields["avm/monthlyCostUSD"] = strconv.FormatFloat(f.MonthlyCost, 'f', 2, 64)
+	}
+	if f.MonthlySavings > 0 {
+		fields["avm/monthlySavingsUSD"] = strconv.FormatFloat(f.MonthlySavings, 'f', 2, 64)
+	}
+	for name, value := range f.Attributes {
+		fields["avm/attributes/"+name] = value
+	}
+
+	finding := &securityhub.AwsSecurityFinding{
+		SchemaVersion: aws.String(asffSchemaVersion),
+		Id:            aws.String(asffID(f, region)),
+		ProductArn:    aws.String(securityHubProductARN(region, f.AccountID)),
+		GeneratorId:   aws.String(f.CheckID),
+		AwsAccountId:  aws.String(f.AccountID),
+		Region:        aws.String(region),
+		Types:         aws.StringSlice([]string{findingType}),
+		CreatedAt:     aws.String(createdAt),
+		UpdatedAt:     aws.String(now),
+		Severity:      &securityhub.Severity{Label: aws.String(asffSeverities[f.Severity])},
+		Title:         aws.String(truncateText(title, 256)),
+		Description:   aws.String(truncateText(f.Message, 1024)),
+		ProductFields: aws.StringMap(fields),
+		Resources:     []*securityhub.Resource{asffResource(f, region)},
+		Compliance:    &securityhub.Compliance{Status: aws.String(securityhub.ComplianceStatusFailed)},
+		RecordState:   aws.String(securityhub.RecordStateActive),
+	}
+	if help != "" {
+		finding.Remediation = &securityhub.Remediation{Recommendation: &securityhub.Recommendation{Text: aws.String(truncateText(help, 512))}}
+	}
+	return finding
+}
+
+// exportToSecurityHub imports the findings of account into Security Hub, in the region they were
+// found in and global findings in the home region of clients. Findings imported by an earlier
+// scan that avm no longer reports are archived, for the checks that ran without error.
+func exportToSecurityHub(ctx context.Context, clients *Clients, account AccountReport, progress io.Writer) error {
+	type importRegion struct {
+		findings []Finding
+		// checks holds the checks whose earlier findings may be archived
+		checks map[string]bool
+	}
+	regions := make(map[string]*importRegion)
+	var order []string
+	for _, r := range account.Regions {
+		code := r.AccountInformation.RegionCode
+		if code == globalRegion {
+			code = clients.Region
+		}
+		if regions[code] == nil {
+			regions[code] = &importRegion{checks: make(map[string]bool)}
+			order = append(order, code)
+		}
+		regions[code].findings = append(regions[code].findings, r.Results...)
+		for _, id := range r.ChecksRun {
+			if _, failed := r.Errors[id]; !failed {
+				regions[code].checks[id] = true
+			}
+		}
+	}
+
+	for _, code := range order {
+		regionClients, err := clients.forRegion(code)
+		if err != nil {
+			return err
+		}
+		imported, archived, err := importRegionFindings(ctx, regionClients.SecurityHub, account.AccountId, code, regions[code].findings, regions[code].checks)
+		if err != nil {
+			return fmt.Errorf("failed to export to Security Hub in %s: %v", code, err)
+		}
+		fmt.Fprintf(progress, "🛡️  Security Hub %s: %d findings imported, %d archived\n", code, imported, archived)
+	}
+	return nil
+}
+
+// importRegionFindings imports findings into the Security Hub of one region and archives the
+// active findings of checks that are no longer reported
+func importRegionFindings(ctx context.Context, hub *securityhub.SecurityHub, accountID, region string, findings []Finding, checks map[string]bool) (imported, archived int, err error) {
+	productARN := securityHubProductARN(region, accountID)
+	active := make(map[string]*securityhub.AwsSecurityFinding)
+	err = hub.GetFindingsPagesWithContext(ctx, &securityhub.GetFindingsInput{
+		Filters: &securityhub.AwsSecurityFindingFilters{
+			ProductArn:   []*securityhub.StringFilter{{Comparison: aws.String(securityhub.StringFilterComparisonEquals), Value: aws.String(productARN)}},
+			AwsAccountId: []*securityhub.StringFilter{{Comparison: aws.String(securityhub.StringFilterComparisonEquals), Value: aws.String(accountID)}},
+			RecordState:  []*securityhub.StringFilter{{Comparison: aws.String(securityhub.StringFilterComparisonEquals), Value: aws.String(securityhub.RecordStateActive)}},
+			Id:           []*securityhub.StringFilter{{Comparison: aws.String(securityhub.StringFilterComparisonPrefix), Value: aws.String("avm/")}},
+		},
+		MaxResults: aws.Int64(100),
+	}, func(page *securityhub.GetFindingsOutput, lastPage bool) bool {
+		for _, f := range page.Findings {
+			active[aws.StringValue(f.Id)] = f
+		}
+		return true
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list the findings imported before: %v", err)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	var batch []*securityhub.AwsSecurityFinding
+	seen := make(map[string]bool)
+	for _, f := range findings {
+		id := asffID(f, region)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		createdAt := now
+		if previous, ok := active[id]; ok {
+			createdAt = aws.StringValue(previous.CreatedAt)
+		}
+		batch = append(batch, asffFinding(f, region, createdAt, now))
+	}
+	imported = len(batch)
+	for id, previous := range active {
+		if seen[id] || !checks[aws.StringValue(previous.GeneratorId)] {
+			continue
+		}
+		// Only the fields a finding provider sets are sent back, Security Hub keeps its own
+		batch = append(batch, &securityhub.AwsSecurityFinding{
+			SchemaVersion: previous.SchemaVersion,
+			Id:            previous.Id,
+			ProductArn:    previous.ProductArn,
+			GeneratorId:   previous.GeneratorId,
+			AwsAccountId:  previous.AwsAccountId,
+			Region:        previous.Region,
+			Types:         previous.Types,
+			CreatedAt:     previous.CreatedAt,
+			UpdatedAt:     aws.String(now),
+			Severity:      previous.Severity,
+			Title:         previous.Title,
+			Description:   previous.Description,
+			ProductFields: previous.ProductFields,
+			Resources:     previous.Resources,
+			Remediation:   previous.Remediation,
+			Compliance:    &securityhub.Compliance{Status: aws.String(securityhub.ComplianceStatusPassed)},
+			RecordState:   aws.String(securityhub.RecordStateArchived),
+		})
+		archived++
+	}
+
+	for start := 0; start < len(batch); start += securityHubBatchSize {
+		end := start + securityHubBatchSize
+		if end > len(batch) {
+			end = len(batch)
+		}
+		out, err := hub.BatchImportFindingsWithContext(ctx, &securityhub.BatchImportFindingsInput{Findings: batch[start:end]})
+		if err != nil {
+			return 0, 0, err
+		}
+		if failed := aws.Int64Value(out.FailedCount); failed > 0 {
+			first := out.FailedFindings[0]
+			return 0, 0, fmt.Errorf("%d findings rejected, the first one (%s): %s %s", failed, aws.StringValue(first.Id),
+				aws.StringValue(first.ErrorCode), aws.StringValue(first.ErrorMessage))
+		}
+	}
+	return imported, archived, nil
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const serveUsageText = `Usage:
+  avm serve --schedule "0 6 * * *" [scan flags]
+  avm serve --listen :8080 [--schedule "0 6 * * *"] [scan flags]
+
+Keeps running and scans on a cron schedule, in local time unless the schedule starts with
+CRON_TZ=. Every scan is saved to the history and findings that were not reported by the
+previous scheduled scan are logged. Takes the same flags as 'avm scan'; with --out the report of the
+last scan is written to the file, and with --slack-webhook or --webhook a summary of every
+scan is posted. With --listen the outcome of the last scheduled scan is
+exposed to Prometheus at /metrics, and scans can be started and their findings read with a JSON
+API: POST /scans, GET /scans/{id}, GET /scans/{id}/findings and GET /checks. --schedule can be
+left out when --listen is given, to only scan when asked through the API.
+
+Flags:
+`
+
+// notification is sent after every scheduled scan
+type notification struct {
+	// Run is the run the scan was saved as in the history, with ID 0 when there is no history
+	Run historyRun
+	// New are the findings the previous scan did not have, none when there is no previous scan
+	New    []Finding
+	Report ScanReport
+}
+
+// notifier is told about every scheduled scan, and picks the findings it reports
+type notifier interface {
+	Notify(ctx context.Context, n notification) error
+}
+
+// logNotifier writes the new findings to the log of the daemon
+type logNotifier struct {
+	logger *log.Logger
+}
+
+func (l logNotifier) Notify(ctx context.Context, n notification) error {
+	sortFindings(n.New)
+	for _, f := range n.New {
+		l.logger.Printf("🆕 [%s] %s %s/%s: %s", f.Severity, f.CheckID, f.AccountID, f.Region, f.Message)
+	}
+	return nil
+}
+
+// daemon runs the configured scan on a schedule until its context is cancelled
+type daemon struct {
+	cfg       *scanConfig
+	schedule  cron.Schedule
+	notifiers []notifier
+	logger    *log.Logger
+	metrics   *scanMetrics
+	apiToken  string
+	// serveAPI is false when the JSON API is left out of the HTTP server, see apiAllowed
+	serveAPI bool
+
+	// ctx is cancelled when the daemon shuts down, and stops the scans started through the API
+	ctx      context.Context
+	scans    scanJobs
+	apiSlots chan struct{}
+
+	// previous holds the report of the last scheduled scan, to tell which findings are new
+	previous    ScanReport
+	hasPrevious bool
+}
+
+func runServeCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("avm serve", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), serveUsageText)
+		fs.PrintDefaults()
+	}
+	schedule := fs.String("schedule", "", "cron schedule to scan on, e.g. \"0 6 * * *\" for every day at 06:00, or @hourly")
+	runNow := fs.Bool("run-now", false, "also scan once at start, without waiting for the schedule")
+	listen := fs.String("listen", "", "address to serve /metrics and the JSON API on, e.g. :8080")
+	apiToken := fs.String("api-token", os.Getenv("AVM_API_TOKEN"), "bearer token the JSON API requires, defaults to $AVM_API_TOKEN")
+	buildConfig := addScanFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if *schedule == "" && *listen == "" {
+		return errors.New("--schedule or --listen is required, e.g. --schedule \"0 6 * * *\"")
+	}
+	serveAPI := *listen != "" && apiAllowed(*listen, *apiToken)
+	if *listen != "" && !serveAPI && *schedule == "" {
+		return fmt.Errorf("the JSON API needs --api-token (or AVM_API_TOKEN) unless --listen is a loopback address such as 127.0.0.1%s", listenPort(*listen))
+	}
+	var parsed cron.Schedule
+	if *schedule != "" {
+		var err error
+		if parsed, err = cron.ParseStandard(*schedule); err != nil {
+			return fmt.Errorf("invalid --schedule %q: %v", *schedule, err)
+		}
+	}
+	cfg, err := buildConfig()
+	if err != nil {
+		return err
+	}
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	d := &daemon{
+		cfg:       cfg,
+		schedule:  parsed,
+		notifiers: append([]notifier{logNotifier{logger: logger}}, newNotifiers(cfg.Notify)...),
+		logger:    logger,
+		metrics:   newScanMetrics(),
+		apiToken:  *apiToken,
+		serveAPI:  serveAPI,
+		ctx:       ctx,
+		apiSlots:  make(chan struct{}, maxAPIScans),
+	}
+	d.loadPrevious()
+	if *listen == "" {
+		return d.run(ctx, *runNow)
+	}
+	return d.serveHTTP(ctx, *listen, *runNow)
+}
+
+func (d *daemon) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", d.metrics.handler())
+	if !d.serveAPI {
+		return mux
+	}
+	mux.Handle("/scans", requireToken(d.apiToken, http.HandlerFunc(d.handleScans)))
+	mux.Handle("/scans/", requireToken(d.apiToken, http.HandlerFunc(d.handleScans)))
+	mux.Handle("/checks", requireToken(d.apiToken, http.HandlerFunc(d.handleChecks)))
+	return mux
+}
+
+// apiAllowed reports whether the JSON API may be served on addr: with a token, or without one
+// only on a loopback address, as the API assumes roles with the credentials of avm serve
+func apiAllowed(addr, token string) bool {
+	if token != "" {
+		return true
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// listenPort returns the :port part of addr, for error messages
+func listenPort(addr string) string {
+	if _, port, err := net.SplitHostPort(addr); err == nil {
+		return ":" + port
+	}
+	return ":8080"
+}
+
+// serveHTTP runs the daemon with an HTTP server on addr, until ctx is cancelled or the server fails
+func (d *daemon) serveHTTP(ctx context.Context, addr string, runNow bool) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", addr, err)
+	}
+	server := &http.Server{Handler: d.routes(), ReadHeaderTimeout: 10 * time.Second}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	d.ctx = ctx
+	serverErr := make(chan error, 1)
+	go func() {
+		if err := server.Serve(listener); err != http.ErrServerClosed {
+			serverErr <- err
+			cancel()
+		}
+	}()
+	if d.serveAPI {
+		d.logger.Printf("Serving /metrics and the API on %s", listener.Addr())
+	} else {
+		d.logger.Printf("Serving /metrics on %s; the API is not served without --api-token on an address that is not loopback", listener.Addr())
+	}
+
+	err = d.run(ctx, runNow)
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelShutdown()
+	server.Shutdown(shutdownCtx)
+	select {
+	case err := <-serverErr:
+		return fmt.Errorf("HTTP server failed: %v", err)
+	default:
+		return err
+	}
+}
+
+// loadPrevious starts from the last scheduled run of the same configuration in the history, so
+// that a restart does not report every finding as new. Scans started through the API or by
+// 'avm scan' may have looked at other accounts, regions or checks, and are left out.
+func (d *daemon) loadPrevious() {
+	if d.cfg.History == "" {
+		return
+	}
+	history, err := openHistory(d.cfg.History)
+	if err != nil {
+		d.logger.Printf("⚠️  Cannot read the history, every finding of the first scan is taken as known: %v", err)
+		return
+	}
+	defer history.Close()
+	run, report, ok, err := history.LastOf(historyConfig(d.cfg), triggerSchedule)
+	if err != nil {
+		d.logger.Printf("⚠️  Cannot read the last run of the history: %v", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	d.previous, d.hasPrevious = report, true
+	d.logger.Printf("Comparing new findings with run %d of %s", run.ID, run.Time.Local().Format("2006-01-02 15:04"))
+}
+
+// run scans every time the schedule is due, until ctx is cancelled. A scan that is running when
+// ctx is cancelled stops its AWS calls and is not saved. Scans that fall due while another one
+// is still running are skipped. Without a schedule it only waits for ctx.
+func (d *daemon) run(ctx context.Context, runNow bool) error {
+	if runNow {
+		d.scanOnce(ctx)
+	}
+	if d.schedule == nil {
+		<-ctx.Done()
+		d.logger.Printf("Shutting down")
+		return nil
+	}
+	for {
+		next := d.schedule.Next(time.Now())
+		d.logger.Printf("Next scan at %s", next.Format("2006-01-02 15:04:05 MST"))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			d.logger.Printf("Shutting down")
+			return nil
+		case <-timer.C:
+		}
+		d.scanOnce(ctx)
+	}
+}
+
+// scanOnce runs a scheduled scan. Only scheduled scans update the metrics, the --out file and
+// notify, so that a scan started through the API with other regions or checks does not make
+// findings look resolved.
+func (d *daemon) scanOnce(ctx context.Context) {
+	cfg := *d.cfg
+	report, run, err := d.execute(ctx, d.scans.add(triggerSchedule, &cfg))
+	if err != nil {
+		return
+	}
+	d.metrics.observe(report, time.Now())
+
+	if d.cfg.OutFile != "" {
+		if err := writeReportFile(d.cfg.OutFile, d.cfg.Output, report); err != nil {
+			d.logger.Printf("❌ %v", err)
+		}
+	}
+	if d.cfg.Upload.enabled() {
+		var uploaded bytes.Buffer
+		if err := uploadScan(ctx, d.cfg, report, time.Now(), &uploaded); err != nil {
+			d.logger.Printf("❌ %v", err)
+		} else {
+			d.logger.Print(strings.TrimSpace(uploaded.String()))
+		}
+	}
+
+	n := notification{Run: run, Report: report}
+	if d.hasPrevious {
+		diff := diffReports(d.previous, report)
+		n.New = diff.New
+		d.logger.Printf("%d new and %d resolved findings since the previous scan", len(diff.New), len(diff.Resolved))
+		if diff.ScopeDiffers {
+			d.logger.Printf("⚠️  This scan and the previous one did not both run every check without error, %d findings are not compared", diff.OutOfScope)
+		}
+	} else {
+		d.logger.Printf("First scan, later scans will notify about findings that are not in this one")
+	}
+	d.previous, d.hasPrevious = report, true
+	for _, err := range notifyAll(ctx, d.notifiers, n) {
+		d.logger.Printf("⚠️  %v", err)
+	}
+}
+
+// saveRun saves a report of a scan of cfg started by origin to the history, holding the database
+// only while writing so that 'avm history' and 'avm diff' can read it in between scans
+func saveRun(cfg *scanConfig, origin string, report ScanReport) (historyRun, error) {
+	history, err := openHistory(cfg.History)
+	if err != nil {
+		return historyRun{}, err
+	}
+	defer history.Close()
+	return history.Save(report, time.Now(), origin, historyConfig(cfg))
+}
+
+// writeReportFile replaces path with the report, so that readers never see a half written one
+func writeReportFile(path, format string, report ScanReport) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	defer os.Remove(file.Name())
+	if err := renderers[format](file, report); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
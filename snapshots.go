@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+const (
+	// defaultSnapshotAge is how many days old a snapshot no AMI uses has to be to be reported
+	defaultSnapshotAge = 180
+
+	// copiedSnapshotVolume is the volume ID of snapshots that were copied or imported
+	copiedSnapshotVolume = "vol-ffffffff"
+)
+
+var (
+	unencryptedSnapshotsCheck = &basicCheck{
+		id:          "ebs-unencrypted-snapshots",
+		service:     "ebs",
+		severity:    SeverityMedium,
+		category:    CategorySecurity,
+		description: "Unencrypted EBS snapshots",
+		help:        "Anyone the snapshot is shared with can read its data. Copy the snapshot with encryption enabled and delete the unencrypted one, and turn on EBS encryption by default for the region.",
+		arn:         "arn:{partition}:ec2:{region}::snapshot/{id}",
+	}
+	snapshotsWithoutVolumeCheck = &basicCheck{
+		id:          "ebs-snapshots-without-volume",
+		service:     "ebs",
+		severity:    SeverityLow,
+		category:    CategoryHygiene,
+		description: "EBS snapshots of volumes that no longer exist",
+		help:        "The volume the snapshot was taken of was deleted and no AMI uses the snapshot. Delete the snapshot unless it is kept as a backup on purpose, in which case tag it and consider the archive tier.",
+		arn:         "arn:{partition}:ec2:{region}::snapshot/{id}",
+	}
+	oldSnapshotsCheck = &basicCheck{
+		id:          "ebs-old-snapshots",
+		service:     "ebs",
+		severity:    SeverityLow,
+		category:    CategoryCost,
+		description: "Old EBS snapshots not used by any AMI",
+		help:        "Delete snapshots that are no longer needed. Snapshots that must be kept for 90 days or more are about 75% cheaper in the archive tier ('aws ec2 modify-snapshot-tier --storage-tier archive'), at the cost of a restore of up to 72 hours before use.",
+		arn:         "arn:{partition}:ec2:{region}::snapshot/{id}",
+	}
+	sharedSnapshotsCheck = &basicCheck{
+		id:          "ebs-snapshots-shared-externally",
+		service:     "ebs",
+		severity:    SeverityHigh,
+		category:    CategorySecurity,
+		description: "EBS snapshots shared with untrusted accounts",
+		help:        "The accounts can create volumes from the snapshot and read its data. Remove them from the createVolumePermission attribute of the snapshot, or add them to --trusted-accounts if the sharing is meant.",
+		arn:         "arn:{partition}:ec2:{region}::snapshot/{id}",
+	}
+)
+
+func init() {
+	unencryptedSnapshotsCheck.run = runUnencryptedSnapshotsCheck
+	snapshotsWithoutVolumeCheck.run = runSnapshotsWithoutVolumeCheck
+	oldSnapshotsCheck.run = runOldSnapshotsCheck
+	sharedSnapshotsCheck.run = runSharedSnapshotsCheck
+
+	registerCheck(unencryptedSnapshotsCheck)
+	registerCheck(snapshotsWithoutVolumeCheck)
+	registerCheck(oldSnapshotsCheck)
+	registerCheck(sharedSnapshotsCheck)
+}
+
+// regionVolumes returns every EBS volume in the region of report, listed once for every check
+// that needs them
+func regionVolumes(ctx context.Context, clients *Clients, report *Report) ([]*ec2.Volume, error) {
+	value, err := report.shared("volumes", func() (interface{}, error) {
+		var volumes []*ec2.Volume
+		err := clients.EC2.DescribeVolumesPagesWithContext(ctx, &ec2.DescribeVolumesInput{
+			MaxResults: aws.Int64(500),
+		}, func(page *ec2.DescribeVolumesOutput, lastPage bool) bool {
+			volumes = append(volumes, page.Volumes...)
+			return true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe volumes: %v", err)
+		}
+		return volumes, nil
+	})
+	volumes, _ := value.([]*ec2.Volume)
+	return volumes, err
+}
+
+// regionImages returns the AMIs owned by the account in the region of report, listed once for
+// every check that needs them
+func regionImages(ctx context.Context, clients *Clients, report *Report) ([]*ec2.Image, error) {
+	value, err := report.shared("images", func() (interface{}, error) {
+		var images []*ec2.Image
+		err := clients.EC2.DescribeImagesPagesWithContext(ctx, &ec2.DescribeImagesInput{
+			Owners:     []*string{aws.String("self")},
+			MaxResults: aws.Int64(1000),
+		}, func(page *ec2.DescribeImagesOutput, lastPage bool) bool {
+			images = append(images, page.Images...)
+			return true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe images: %v", err)
+		}
+		return images, nil
+	})
+	images, _ := value.([]*ec2.Image)
+	return images, err
+}
+
+// imageSnapshotIds returns the snapshots the block devices of images are created from
+func imageSnapshotIds(images []*ec2.Image) map[string]bool {
+	ids := make(map[string]bool)
+	for _, image := range images {
+		for _, mapping := range image.BlockDeviceMappings {
+			if mapping.Ebs != nil && mapping.Ebs.SnapshotId != nil {
+				ids[*mapping.Ebs.SnapshotId] = true
+			}
+		}
+	}
+	return ids
+}
+
+// completedSnapshots leaves out the snapshots still being created
+func completedSnapshots(snapshots []*ec2.Snapshot) []*ec2.Snapshot {
+	var completed []*ec2.Snapshot
+	for _, snapshot := range snapshots {
+		if aws.StringValue(snapshot.State) == ec2.SnapshotStateCompleted {
+			completed = append(completed, snapshot)
+		}
+	}
+	return completed
+}
+
+func runUnencryptedSnapshotsCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	snapshots, err := regionSnapshots(ctx, clients, report)
+	if err != nil {
+		return nil, err
+	}
+	report.RecordEvaluated(unencryptedSnapshotsCheck.id, getSnapshotIds(snapshots))
+	var findings []Finding
+	var unencrypted []string
+	for _, snapshot := range completedSnapshots(snapshots) {
+		if aws.BoolValue(snapshot.Encrypted) {
+			continue
+		}
+		id := aws.StringValue(snapshot.SnapshotId)
+		unencrypted = append(unencrypted, id)
+		findings = append(findings, unencryptedSnapshotsCheck.finding(id,
+			"Snapshot %s of volume %s (%d GB) is not encrypted", id, aws.StringValue(snapshot.VolumeId), aws.Int64Value(snapshot.VolumeSize)).withTags(ec2Tags(snapshot.Tags)))
+	}
+	report.Update(func(f *Findings) {
+		f.Snapshots.UnencryptedSnapshotIds = unencrypted
+	})
+	return findings, nil
+}
+
+func runSnapshotsWithoutVolumeCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	snapshots, err := regionSnapshots(ctx, clients, report)
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := regionVolumes(ctx, clients, report)
+	if err != nil {
+		return nil, err
+	}
+	images, err := regionImages(ctx, clients, report)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool, len(volumes))
+	for _, volume := range volumes {
+		existing[aws.StringValue(volume.VolumeId)] = true
+	}
+	usedByImages := imageSnapshotIds(images)
+	prices := regionEBSPrices(clients, report, snapshotsWithoutVolumeCheck.id)
+
+	report.RecordEvaluated(snapshotsWithoutVolumeCheck.id, getSnapshotIds(snapshots))
+	var findings []Finding
+	var withoutVolume []string
+	for _, snapshot := range completedSnapshots(snapshots) {
+		id, volumeID := aws.StringValue(snapshot.SnapshotId), aws.StringValue(snapshot.VolumeId)
+		// The volumes of AMI snapshots are usually deleted once the AMI is built
+		if volumeID == "" || volumeID == copiedSnapshotVolume || existing[volumeID] || usedByImages[id] || createdForImage(snapshot) {
+			continue
+		}
+		cost := prices.snapshotCost(snapshot)
+		withoutVolume = append(withoutVolume, id)
+		findings = append(findings, snapshotsWithoutVolumeCheck.finding(id,
+			"Snapshot %s of deleted volume %s, %d GB, taken on %s, costs up to $%.2f per month",
+			id, volumeID, aws.Int64Value(snapshot.VolumeSize), aws.TimeValue(snapshot.StartTime).Format("2006-01-02"), cost).withTags(ec2Tags(snapshot.Tags)).withMonthlyCost(cost))
+	}
+	report.Update(func(f *Findings) {
+		f.Snapshots.WithoutVolumeSnapshotIds = withoutVolume
+	})
+	return findings, nil
+}
+
+func runOldSnapshotsCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	snapshots, err := regionSnapshots(ctx, clients, report)
+	if err != nil {
+		return nil, err
+	}
+	images, err := regionImages(ctx, clients, report)
+	if err != nil {
+		return nil, err
+	}
+	usedByImages := imageSnapshotIds(images)
+	cutoff := time.Now().Add(-clients.SnapshotAge)
+	prices := regionEBSPrices(clients, report, oldSnapshotsCheck.id)
+
+	report.RecordEvaluated(oldSnapshotsCheck.id, getSnapshotIds(snapshots))
+	var findings []Finding
+	var old []string
+	var storage SnapshotStorageCost
+	for _, snapshot := range completedSnapshots(snapshots) {
+		id, size := aws.StringValue(snapshot.SnapshotId), aws.Int64Value(snapshot.VolumeSize)
+		archived := aws.StringValue(snapshot.StorageTier) == ec2.StorageTierArchive
+		cost := prices.snapshotCost(snapshot)
+		storage.MonthlyCost += cost
+		if archived {
+			storage.ArchiveTierGB += size
+		} else {
+			storage.StandardTierGB += size
+		}
+		if usedByImages[id] || createdForImage(snapshot) || aws.TimeValue(snapshot.StartTime).After(cutoff) {
+			continue
+		}
+
+		old = append(old, id)
+		days := daysSince(aws.TimeValue(snapshot.StartTime))
+		message := fmt.Sprintf("Snapshot %s of %d GB is %d days old and not used by any AMI, it costs up to $%.2f per month", id, size, days, cost)
+		if !archived {
+			savings := float64(size) * (prices.Snapshots.Standard - prices.Snapshots.Archive)
+			storage.ArchiveCandidatesGB += size
+			storage.ArchiveSavings += savings
+			message += fmt.Sprintf("; delete it, or archive it to save up to $%.2f per month", savings)
+		}
+		findings = append(findings, oldSnapshotsCheck.finding(id, "%s", message).withTags(ec2Tags(snapshot.Tags)).withMonthlyCost(cost))
+	}
+	report.Update(func(f *Findings) {
+		f.Snapshots.OldSnapshotIds = old
+		f.Snapshots.StorageCost = &storage
+	})
+	return findings, nil
+}
+
+func runSharedSnapshotsCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	sharing, err := regionSnapshotSharing(ctx, clients, report)
+	if sharing == nil {
+		return nil, err
+	}
+	report.RecordEvaluated(sharedSnapshotsCheck.id, sharing.checkedIds)
+	var findings []Finding
+	shared := make(map[string][]string)
+	for _, id := range sharing.checkedIds {
+		var untrusted []string
+		for _, account := range sharing.accounts[id] {
+			if !contains(clients.TrustedAccounts, account) {
+				untrusted = append(untrusted, account)
+			}
+		}
+		if len(untrusted) == 0 {
+			continue
+		}
+		sort.Strings(untrusted)
+		shared[id] = untrusted
+		findings = append(findings, sharedSnapshotsCheck.finding(id,
+			"Snapshot %s is shared with accounts that are not trusted: %s", id, strings.Join(untrusted, ", ")).withAttribute("untrustedAccounts", strings.Join(untrusted, ",")))
+	}
+	sort.Slice(findings, func(i, j int) bool { return findings[i].ResourceID < findings[j].ResourceID })
+	report.Update(func(f *Findings) {
+		f.Snapshots.SharedWithUntrustedAccounts = shared
+	})
+	if sharing.sampled {
+		report.Note(sharedSnapshotsCheck.id, "%s", sharing.coverage())
+	}
+	return findings, err
+}
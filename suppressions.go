@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// suppressionDateLayout is how expiry dates are written in the suppressions file
+const suppressionDateLayout = "2006-01-02"
+
+// Suppression waives the findings of one check for a resource, given by ID or ARN, or for every
+// resource carrying all of Tags. Until it expires, matching findings are reported as suppressed
+// and do not count towards --fail-on.
+type Suppression struct {
+	Check    string            `yaml:"check" json:"check"`
+	Resource string            `yaml:"resource,omitempty" json:"resource,omitempty"`
+	Tags     map[string]string `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Reason   string            `yaml:"reason" json:"reason"`
+	// Expires is the last day the suppression applies, empty for one that never expires
+	Expires string `yaml:"expires,omitempty" json:"expires,omitempty"`
+
+	expires time.Time
+}
+
+// SuppressedFinding is a finding matched by a suppression. In the suppressed section of a report
+// the suppression is in force; in the expired section it is not, and the finding is reported again.
+type SuppressedFinding struct {
+	Finding     Finding     `json:"finding"`
+	Suppression Suppression `json:"suppression"`
+}
+
+type suppressionFile struct {
+	Suppressions []Suppression `yaml:"suppressions"`
+}
+
+// loadSuppressions reads a suppressions file such as:
+//
+//	suppressions:
+//	  - check: sg-open-to-world
+//	    resource: sg-0123456789abcdef0
+//	    reason: bastion host, port 22 is also restricted by the network ACL
+//	    expires: 2025-12-31
+//	  - check: ec2-imdv1
+//	    tags:
+//	      Environment: sandbox
+//	    reason: sandbox instances are rebuilt every night
+func loadSuppressions(path string) ([]Suppression, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suppressions file: %v", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var file suppressionFile
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse suppressions file %s: %v", path, err)
+	}
+
+	known := make(map[string]bool)
+	for _, c := range registeredChecks() {
+		known[c.ID()] = true
+	}
+	for i := range file.Suppressions {
+		s := &file.Suppressions[i]
+		where := fmt.Sprintf("%s: suppression %d", path, i+1)
+		if !known[s.Check] {
+			return nil, fmt.Errorf("%s: unknown check %q, see 'avm checks list'", where, s.Check)
+		}
+		if s.Resource == "" && len(s.Tags) == 0 {
+			return nil, fmt.Errorf("%s: needs a resource or tags to match", where)
+		}
+		if strings.TrimSpace(s.Reason) == "" {
+			return nil, fmt.Errorf("%s: needs a reason", where)
+		}
+		if s.Expires != "" {
+			if s.expires, err = time.Parse(suppressionDateLayout, s.Expires); err != nil {
+				return nil, fmt.Errorf("%s: invalid expiry date %q, expected YYYY-MM-DD", where, s.Expires)
+			}
+		}
+	}
+	return file.Suppressions, nil
+}
+
+// expired reports whether the last day of the suppression is before now
+func (s Suppression) expired(now time.Time) bool {
+	return !s.expires.IsZero() && !now.Before(s.expires.AddDate(0, 0, 1))
+}
+
+func (s Suppression) matches(f Finding) bool {
+	if s.Check != f.CheckID {
+		return false
+	}
+	if s.Resource != "" && !resourceMatches(s.Resource, f) {
+		return false
+	}
+	for key, value := range s.Tags {
+		if tag, ok := f.Tags[key]; !ok || tag != value {
+			return false
+		}
+	}
+	return true
+}
+
+// resourceMatches compares the resource of a suppression with the resource of a finding. Findings
+// carry plain IDs, so an ARN matches when it ends with the ID and its account and region, when
+// present, are those of the finding.
+func resourceMatches(resource string, f Finding) bool {
+	if resource == f.ResourceID {
+		return true
+	}
+	if !strings.HasPrefix(resource, "arn:") {
+		return false
+	}
+	// arn:partition:service:region:account:resource
+	parts := strings.SplitN(resource, ":", 6)
+	if len(parts) != 6 {
+		return false
+	}
+	region, account, id := parts[3], parts[4], parts[5]
+	if region != "" && f.Region != globalRegion && region != f.Region {
+		return false
+	}
+	if account != "" && account != f.AccountID {
+		return false
+	}
+	return id == f.ResourceID || strings.HasSuffix(id, "/"+f.ResourceID) || strings.HasSuffix(id, ":"+f.ResourceID)
+}
+
+// applySuppressions splits findings into the ones still reported and the ones suppressed. Findings
+// matched only by expired suppressions are reported, and returned in expired as well.
+func applySuppressions(suppressions []Suppression, findings []Finding, now time.Time) (reported []Finding, suppressed, expired []SuppressedFinding) {
+	for _, f := range findings {
+		var active, lapsed *Suppression
+		for i := range suppressions {
+			if !suppressions[i].matches(f) {
+				continue
+			}
+			if suppressions[i].expired(now) {
+				if lapsed == nil {
+					lapsed = &suppressions[i]
+				}
+			} else {
+				active = &suppressions[i]
+				break
+			}
+		}
+		switch {
+		case active != nil:
+			suppressed = append(suppressed, SuppressedFinding{Finding: f, Suppression: *active})
+		case lapsed != nil:
+			expired = append(expired, SuppressedFinding{Finding: f, Suppression: *lapsed})
+			reported = append(reported, f)
+		default:
+			reported = append(reported, f)
+		}
+	}
+	return reported, suppressed, expired
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestApplySuppressions(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	suppression := func(check, resource string, tags map[string]string, expires string) Suppression {
+		s := Suppression{Check: check, Resource: resource, Tags: tags, Reason: "test", Expires: expires}
+		if expires != "" {
+			s.expires, _ = time.Parse(suppressionDateLayout, expires)
+		}
+		return s
+	}
+	sg := Finding{CheckID: "sg-open-to-world", AccountID: "111111111111", Region: "us-east-1", ResourceID: "sg-1", Message: "sg-1"}
+	sandbox := Finding{CheckID: "ec2-imdv1", AccountID: "111111111111", Region: "us-east-1", ResourceID: "i-1",
+		Tags: map[string]string{"Environment": "sandbox", "Team": "data"}, Message: "i-1"}
+	global := Finding{CheckID: "iam-root-access-keys", AccountID: "111111111111", Region: globalRegion, ResourceID: "root", Message: "root"}
+
+	tests := []struct {
+		name                          string
+		suppressions                  []Suppression
+		findings                      []Finding
+		reported, suppressed, expired []string
+	}{
+		{
+			name:     "no suppressions",
+			findings: []Finding{sg},
+			reported: []string{"sg-1"},
+		},
+		{
+			name:         "by resource ID",
+			suppressions: []Suppression{suppression("sg-open-to-world", "sg-1", nil, "")},
+			findings:     []Finding{sg, sandbox},
+			reported:     []string{"i-1"},
+			suppressed:   []string{"sg-1"},
+		},
+		{
+			name:         "other check",
+			suppressions: []Suppression{suppression("ec2-imdv1", "sg-1", nil, "")},
+			findings:     []Finding{sg},
+			reported:     []string{"sg-1"},
+		},
+		{
+			name:         "by ARN",
+			suppressions: []Suppression{suppression("sg-open-to-world", "arn:aws:ec2:us-east-1:111111111111:security-group/sg-1", nil, "")},
+			findings:     []Finding{sg},
+			suppressed:   []string{"sg-1"},
+		},
+		{
+			name:         "ARN of another region",
+			suppressions: []Suppression{suppression("sg-open-to-world", "arn:aws:ec2:eu-west-1:111111111111:security-group/sg-1", nil, "")},
+			findings:     []Finding{sg},
+			reported:     []string{"sg-1"},
+		},
+		{
+			name:         "ARN of another account",
+			suppressions: []Suppression{suppression("sg-open-to-world", "arn:aws:ec2:us-east-1:222222222222:security-group/sg-1", nil, "")},
+			findings:     []Finding{sg},
+			reported:     []string{"sg-1"},
+		},
+		{
+			name:         "regional ARN of a global finding",
+			suppressions: []Suppression{suppression("iam-root-access-keys", "arn:aws:iam:us-east-1:111111111111:root", nil, "")},
+			findings:     []Finding{global},
+			suppressed:   []string{"root"},
+		},
+		{
+			name:         "by tags",
+			suppressions: []Suppression{suppression("ec2-imdv1", "", map[string]string{"Environment": "sandbox"}, "")},
+			findings:     []Finding{sandbox},
+			suppressed:   []string{"i-1"},
+		},
+		{
+			name:         "tags must all match",
+			suppressions: []Suppression{suppression("ec2-imdv1", "", map[string]string{"Environment": "sandbox", "Team": "web"}, "")},
+			findings:     []Finding{sandbox},
+			reported:     []string{"i-1"},
+		},
+		{
+			name:         "in force on the expiry date",
+			suppressions: []Suppression{suppression("sg-open-to-world", "sg-1", nil, "2025-06-15")},
+			findings:     []Finding{sg},
+			suppressed:   []string{"sg-1"},
+		},
+		{
+			name:         "expired",
+			suppressions: []Suppression{suppression("sg-open-to-world", "sg-1", nil, "2025-06-14")},
+			findings:     []Finding{sg},
+			reported:     []string{"sg-1"},
+			expired:      []string{"sg-1"},
+		},
+		{
+			name: "active suppression wins over an expired one",
+			suppressions: []Suppression{
+				suppression("sg-open-to-world", "sg-1", nil, "2025-01-01"),
+				suppression("sg-open-to-world", "sg-1", nil, ""),
+			},
+			findings:   []Finding{sg},
+			suppressed: []string{"sg-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reported, suppressed, expired := applySuppressions(tt.suppressions, tt.findings, now)
+			var got []string
+			for _, f := range reported {
+				got = append(got, f.Message)
+			}
+			if !reflect.DeepEqual(got, tt.reported) {
+				t.Errorf("reported = %q, want %q", got, tt.reported)
+			}
+			got = nil
+			for _, s := range suppressed {
+				got = append(got, s.Finding.Message)
+			}
+			if !reflect.DeepEqual(got, tt.suppressed) {
+				t.Errorf("suppressed = %q, want %q", got, tt.suppressed)
+			}
+			got = nil
+			for _, s := range expired {
+				got = append(got, s.Finding.Message)
+			}
+			if !reflect.DeepEqual(got, tt.expired) {
+				t.Errorf("expired = %q, want %q", got, tt.expired)
+			}
+		})
+	}
+}
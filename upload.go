@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// uploadFormats are the report formats --upload-formats accepts, with the content type they are
+// uploaded with
+var uploadFormats = map[string]string{
+	"json":     "application/json",
+	"html":     "text/html; charset=utf-8",
+	"csv":      "text/csv; charset=utf-8",
+	"markdown": "text/markdown; charset=utf-8",
+	"sarif":    "application/sarif+json",
+	"junit":    "application/xml",
+}
+
+// uploadExtensions are the file extensions of the formats whose name is not one
+var uploadExtensions = map[string]string{
+	"markdown": "md",
+	"sarif":    "sarif.json",
+	"junit":    "xml",
+}
+
+// uploadOptions tell where the reports of every scan are uploaded, see uploadReports
+type uploadOptions struct {
+	Bucket string
+	// Prefix ends with a slash, or is empty for the root of the bucket
+	Prefix  string
+	Formats []string
+	// KMSKey encrypts the reports with SSE-KMS, if set
+	KMSKey string
+}
+
+func (o uploadOptions) enabled() bool {
+	return o.Bucket != ""
+}
+
+// parseS3URL splits s3://bucket/prefix/ into the bucket and the prefix, which ends with a slash
+func parseS3URL(value string) (bucket, prefix string, err error) {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return "", "", fmt.Errorf("invalid --upload %q, expected s3://bucket/prefix/", value)
+	}
+	prefix = strings.Trim(u.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return u.Host, prefix, nil
+}
+
+func (o uploadOptions) validate() error {
+	if !o.enabled() {
+		return nil
+	}
+	if len(o.Formats) == 0 {
+		return fmt.Errorf("--upload-formats needs at least one format")
+	}
+	for _, format := range o.Formats {
+		if uploadFormats[format] == "" {
+			return fmt.Errorf("unknown --upload-formats format %q, expected json, html, csv, markdown, sarif or junit", format)
+		}
+	}
+	return nil
+}
+
+// reportKey is the key a report of one account and region is uploaded to, so that the reports of
+// every scan accumulate as <prefix><account>/<region>/<timestamp>/report.<ext>
+func (o uploadOptions) reportKey(account, region string, t time.Time, format string) string {
+	ext := format
+	if e, ok := uploadExtensions[format]; ok {
+		ext = e
+	}
+	return fmt.Sprintf("%s%s/%s/%s/report.%s", o.Prefix, account, region, t.UTC().Format("20060102T150405Z"), ext)
+}
+
+// reportTags tag a report with its account, region and findings count by severity, as a query
+// string
+func reportTags(account, region string, summary AccountSummary) string {
+	tags := url.Values{}
+	tags.Set("account", account)
+	tags.Set("region", region)
+	tags.Set("findings", strconv.Itoa(summary.TotalFindings))
+	for _, severity := range severities {
+		tags.Set(string(severity), strconv.Itoa(summary.FindingsBySeverity[severity]))
+	}
+	return tags.Encode()
+}
+
+// uploadReports uploads a report of every account and region of report, in every format of o,
+// with the clients of the credentials in use. Accounts that could not be scanned have no report.
+func uploadReports(ctx context.Context, clients *Clients, o uploadOptions, report ScanReport, t time.Time, progress io.Writer) error {
+	bucketClients := clients
+	if clients.factory.cfg.EndpointURL == "" {
+		region, err := s3manager.GetBucketRegionWithClient(ctx, clients.S3, o.Bucket)
+		if err != nil {
+			return fmt.Errorf("failed to upload the reports: cannot find the region of bucket %s: %v", o.Bucket, err)
+		}
+		if bucketClients, err = clients.forRegion(region); err != nil {
+			return err
+		}
+	}
+
+	uploaded := 0
+	for _, account := range report.Accounts {
+		if account.Error != "" {
+			continue
+		}
+		for _, region := range account.Regions {
+			code := region.AccountInformation.RegionCode
+			single := newScanReport([]AccountReport{newAccountReport(region.AccountInformation, []MasterStructure{region})})
+			for _, format := range o.Formats {
+				var body bytes.Buffer
+				if err := renderers[format](&body, single); err != nil {
+					return fmt.Errorf("failed to render the %s report of %s/%s: %v", format, account.AccountId, code, err)
+				}
+				input := &s3.PutObjectInput{
+					Bucket:      aws.String(o.Bucket),
+					Key:         aws.String(o.reportKey(account.AccountId, code, t, format)),
+					Body:        bytes.NewReader(body.Bytes()),
+					ContentType: aws.String(uploadFormats[format]),
+					Tagging:     aws.String(reportTags(account.AccountId, code, single.Accounts[0].Summary)),
+				}
+				if o.KMSKey != "" {
+					input.ServerSideEncryption = aws.String(s3.ServerSideEncryptionAwsKms)
+					input.SSEKMSKeyId = aws.String(o.KMSKey)
+				}
+				if _, err := bucketClients.S3.PutObjectWithContext(ctx, input); err != nil {
+					return fmt.Errorf("failed to upload s3://%s/%s: %v", o.Bucket, aws.StringValue(input.Key), err)
+				}
+				uploaded++
+			}
+		}
+	}
+	fmt.Fprintf(progress, "☁️  Uploaded %d reports to s3://%s/%s\n", uploaded, o.Bucket, o.Prefix)
+	return nil
+}
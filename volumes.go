@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+const (
+	// iopsUsageThreshold is the share of its provisioned IOPS an io1 or io2 volume has to stay
+	// below at its busiest hour to be reported
+	iopsUsageThreshold = 0.2
+	// iopsHeadroom is how many times the busiest hour the IOPS recommended for a volume are
+	iopsHeadroom = 2
+	// minProvisionedIOPS is the least IOPS an io1 or io2 volume can have
+	minProvisionedIOPS = 100
+	// maxMetricDatapoints is the most datapoints GetMetricStatistics returns in one call
+	maxMetricDatapoints = 1440
+)
+
+// EBSVolumeIssue is something to change on one EBS volume: its encryption, a gp2 type that gp3
+// serves for less, or io1 and io2 IOPS that go unused
+type EBSVolumeIssue struct {
+	VolumeId string `json:"volumeId"`
+	Issue    string `json:"issue"`
+	// Detail holds the figures of the issue, which change from one scan to the next
+	Detail string `json:"detail,omitempty"`
+	// Encryption is a security issue, the volume type and IOPS are cost issues
+	Severity Severity `json:"severity"`
+	Category Category `json:"category"`
+	// MonthlySavings is what fixing a cost issue saves, when the volume type has prices
+	MonthlySavings float64 `json:"monthlySavingsUSD,omitempty"`
+}
+
+var ebsOptimizationCheck = &basicCheck{
+	id:          "ebs-volume-optimization",
+	service:     "ebs",
+	severity:    SeverityMedium,
+	category:    CategoryCost,
+	description: "EBS volumes to migrate, downsize or encrypt",
+	help:        "Migrate gp2 volumes to gp3 with at least the same IOPS and throughput, lower the provisioned IOPS of io1 and io2 volumes that do not use them, and replace unencrypted volumes with encrypted copies made from a snapshot. IOPS usage is averaged per hour, so check the workload for shorter bursts before lowering it.",
+	arn:         "arn:{partition}:ec2:{region}:{account}:volume/{id}",
+}
+
+func init() {
+	ebsOptimizationCheck.run = runEBSOptimizationCheck
+	registerCheck(ebsOptimizationCheck)
+}
+
+// gp2Performance returns the baseline IOPS and throughput in MB/s of a gp2 volume of size GB
+func gp2Performance(size int64) (iops, throughput int64) {
+	iops = 3 * size
+	if iops < 100 {
+		iops = 100
+	}
+	if iops > 16000 {
+		iops = 16000
+	}
+	throughput = 128
+	if size > 170 {
+		throughput = 250
+	}
+	return iops, throughput
+}
+
+// gp3Savings returns the monthly saving of migrating a gp2 volume to a gp3 volume with the same
+// baseline performance
+func gp3Savings(prices ebsPrices, volume *ec2.Volume) (float64, bool) {
+	size := aws.Int64Value(volume.Size)
+	iops, throughput := gp2Performance(size)
+	current, ok := prices.volumeTypeCost(ec2.VolumeTypeGp2, size, 0, 0)
+	if !ok {
+		return 0, false
+	}
+	migrated, ok := prices.volumeTypeCost(ec2.VolumeTypeGp3, size, iops, throughput)
+	if !ok {
+		return 0, false
+	}
+	return current.total() - migrated.total(), true
+}
+
+// iopsSavings returns the monthly saving of lowering the provisioned IOPS of a volume of
+// volumeType with size GB to recommended
+func iopsSavings(prices ebsPrices, volumeType string, size, provisioned, recommended int64) (float64, bool) {
+	current, ok := prices.volumeTypeCost(volumeType, size, provisioned, 0)
+	if !ok {
+		return 0, false
+	}
+	lowered, ok := prices.volumeTypeCost(volumeType, size, recommended, 0)
+	if !ok {
+		return 0, false
+	}
+	return current.total() - lowered.total(), true
+}
+
+// metricPeriod is the shortest whole number of hours that fits timeframe in one
+// GetMetricStatistics call
+func metricPeriod(timeframe time.Duration) time.Duration {
+	hours := math.Ceil(timeframe.Hours() / maxMetricDatapoints)
+	if hours < 1 {
+		hours = 1
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// getVolumePeakIOPS returns the read and write operations per second of a volume in its busiest
+// period over timeframe, and false when CloudWatch has no datapoints for it
+func getVolumePeakIOPS(ctx context.Context, svc *cloudwatch.CloudWatch, volumeID string, timeframe time.Duration) (float64, bool, error) {
+	endTime := time.Now()
+	startTime := endTime.Add(-timeframe)
+	period := metricPeriod(timeframe)
+
+	ops := make(map[time.Time]float64)
+	for _, metric := range []string{"VolumeReadOps", "VolumeWriteOps"} {
+		output, err := svc.GetMetricStatisticsWithContext(ctx, &cloudwatch.GetMetricStatisticsInput{
+			Namespace:  aws.String("AWS/EBS"),
+			MetricName: aws.String(metric),
+			Dimensions: []*cloudwatch.Dimension{
+				{
+					Name:  aws.String("VolumeId"),
+					Value: aws.String(volumeID),
+				},
+			},
+			StartTime:  &startTime,
+			EndTime:    &endTime,
+			Period:     aws.Int64(int64(period.Seconds())),
+			Statistics: []*string{aws.String(cloudwatch.StatisticSum)},
+		})
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to get %s of %s: %v", metric, volumeID, err)
+		}
+		for _, datapoint := range output.Datapoints {
+			ops[aws.TimeValue(datapoint.Timestamp)] += aws.Float64Value(datapoint.Sum)
+		}
+	}
+	if len(ops) == 0 {
+		return 0, false, nil
+	}
+
+	peak := 0.0
+	for _, sum := range ops {
+		peak = math.Max(peak, sum/period.Seconds())
+	}
+	return peak, true, nil
+}
+
+// recommendedIOPS leaves iopsHeadroom times the peak IOPS of a volume
+func recommendedIOPS(peak float64) int64 {
+	iops := int64(math.Ceil(peak*iopsHeadroom/100)) * 100
+	if iops < minProvisionedIOPS {
+		iops = minProvisionedIOPS
+	}
+	return iops
+}
+
+func runEBSOptimizationCheck(ctx context.Context, clients *Clients, report *Report) ([]Finding, error) {
+	volumes, err := regionVolumes(ctx, clients, report)
+	if err != nil {
+		return nil, err
+	}
+	prices := regionEBSPrices(clients, report, ebsOptimizationCheck.id)
+	startTime := time.Now().Add(-clients.Timeframe)
+
+	var issues []EBSVolumeIssue
+	var skipped int
+	volumeIDs := make([]string, 0, len(volumes))
+	tags := make(map[string]map[string]string, len(volumes))
+	for _, volume := range volumes {
+		id := aws.StringValue(volume.VolumeId)
+		volumeIDs = append(volumeIDs, id)
+		tags[id] = ec2Tags(volume.Tags)
+		attached := aws.StringValue(volume.State) == ec2.VolumeStateInUse
+		size := aws.Int64Value(volume.Size)
+
+		// Encryption
+		if !aws.BoolValue(volume.Encrypted) {
+			issues = append(issues, EBSVolumeIssue{VolumeId: id, Issue: "Encryption Not Enabled", Severity: SeverityMedium, Category: CategorySecurity})
+		}
+
+		switch volumeType := aws.StringValue(volume.VolumeType); {
+		// gp2 to gp3
+		case attached && volumeType == ec2.VolumeTypeGp2:
+			savings, ok := gp3Savings(prices, volume)
+			if ok && savings <= 0 {
+				continue
+			}
+			iops, throughput := gp2Performance(size)
+			issue := EBSVolumeIssue{
+				VolumeId: id,
+				Issue:    "Using gp2 volume type (Consider migrating to gp3)",
+				Detail:   fmt.Sprintf("gp3 with the same baseline of %d IOPS and %d MB/s costs less", iops, throughput),
+				Severity: SeverityLow,
+				Category: CategoryCost,
+			}
+			// Without prices for gp2 or gp3 the migration still applies, only the savings are unknown
+			if ok {
+				issue.MonthlySavings = savings
+				issue.Detail = fmt.Sprintf("gp3 with the same baseline of %d IOPS and %d MB/s saves $%.2f per month", iops, throughput, savings)
+			}
+			issues = append(issues, issue)
+
+		// Provisioned IOPS
+		case attached && (volumeType == ec2.VolumeTypeIo1 || volumeType == ec2.VolumeTypeIo2):
+			if aws.TimeValue(volume.CreateTime).After(startTime) {
+				skipped++
+				continue
+			}
+			provisioned := aws.Int64Value(volume.Iops)
+			peak, ok, err := getVolumePeakIOPS(ctx, clients.CloudWatch, id, clients.Timeframe)
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			// One volume without metrics does not lose the findings of the others
+			if err != nil {
+				report.Note(ebsOptimizationCheck.id, "Could not read the IOPS of %s from CloudWatch, its provisioned IOPS are not checked: %v", id, err)
+				continue
+			}
+			if !ok || peak >= float64(provisioned)*iopsUsageThreshold {
+				continue
+			}
+			recommended := recommendedIOPS(peak)
+			if recommended >= provisioned {
+				continue
+			}
+			issue := EBSVolumeIssue{
+				VolumeId: id,
+				Issue:    "Provisioned IOPS mostly unused",
+				Detail:   fmt.Sprintf("peak of %.0f of %d provisioned IOPS, %d IOPS would do", peak, provisioned, recommended),
+				Severity: SeverityMedium,
+				Category: CategoryCost,
+			}
+			// Without prices for the volume type the IOPS are still unused, only the savings are unknown
+			if savings, ok := iopsSavings(prices, volumeType, size, provisioned, recommended); ok {
+				issue.MonthlySavings = savings
+				issue.Detail = fmt.Sprintf("peak of %.0f of %d provisioned IOPS, %d IOPS save $%.2f per month", peak, provisioned, recommended, savings)
+			}
+			issues = append(issues, issue)
+		}
+	}
+
+	optimization := EBSOptimization{TotalAnalyzed: len(volumes), Issues: issues, SkippedRecentlyCreated: skipped}
+	var findings []Finding
+	for _, issue := range issues {
+		optimization.TotalMonthlySavings += issue.MonthlySavings
+		message := issue.Issue
+		if issue.Detail != "" {
+			message += ": " + issue.Detail
+		}
+		finding := ebsOptimizationCheck.finding(issue.VolumeId,
+			"Volume ID: %s: %s", issue.VolumeId, message).withTags(tags[issue.VolumeId]).withAttribute("issue", issue.Issue)
+		finding.Severity, finding.Category = issue.Severity, issue.Category
+		if issue.MonthlySavings > 0 {
+			finding = finding.withMonthlySavings(issue.MonthlySavings)
+		}
+		findings = append(findings, finding)
+	}
+	report.RecordEvaluated(ebsOptimizationCheck.id, volumeIDs)
+	report.Update(func(f *Findings) {
+		f.EBSOptimization = optimization
+	})
+	return findings, nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestGP2Performance(t *testing.T) {
+	tests := []struct {
+		size             int64
+		iops, throughput int64
+	}{
+		{1, 100, 128},
+		{33, 100, 128},
+		{34, 102, 128},
+		{170, 510, 128},
+		{171, 513, 250},
+		{1000, 3000, 250},
+		{5334, 16000, 250},
+		{16384, 16000, 250},
+	}
+	for _, tt := range tests {
+		iops, throughput := gp2Performance(tt.size)
+		if iops != tt.iops || throughput != tt.throughput {
+			t.Errorf("gp2Performance(%d) = %d IOPS, %d MB/s, want %d IOPS, %d MB/s", tt.size, iops, throughput, tt.iops, tt.throughput)
+		}
+	}
+}